@@ -15,6 +15,11 @@ const HieraConfig = `Hiera::Config`
 // capabilities of Hiera. Valid values are "dgo" or "pcore".
 const HieraDialect = `Hiera::Dialect`
 
+// HieraPositions is an option that can be used to make the yaml_data and json_data providers retain the line
+// and column of every key in the files that they read. The positions are then used in explanations and error
+// messages. The value must be a boolean.
+const HieraPositions = `Hiera::Positions`
+
 // HieraScope is an option that can be used to pass a variable scope to Hiera. This scope is used
 // by the 'scope' lookup_key provider function and when doing variable interpolations
 const HieraScope = `Hiera::Scope`
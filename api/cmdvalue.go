@@ -0,0 +1,40 @@
+package api
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/lyraproj/dgo/dgo"
+	"github.com/lyraproj/dgo/streamer"
+)
+
+var jsonScalar = regexp.MustCompile(`\A(?:true|false|-?(?:0|[1-9][0-9]*)(?:\.[0-9]+)?(?:[eE][+-]?[0-9]+)?)\z`)
+
+// ParseScalar returns the boolean or number that the given string is the JSON representation of, or nil when the
+// string is something else. Numbers must be written the way EncodeValue writes them so that strings such as "1.10"
+// or "1e3" are not silently reformatted.
+func ParseScalar(s string) dgo.Value {
+	if !jsonScalar.MatchString(s) {
+		return nil
+	}
+	v := streamer.UnmarshalJSON([]byte(s), nil)
+	if string(streamer.MarshalJSON(v, nil)) != s {
+		return nil
+	}
+	return v
+}
+
+// EncodeValue returns the string that the command line of the lookup CLI and the query parameters of the Hiera
+// server parse back into the given value. Strings are sent verbatim unless they would be parsed as something else
+// and all other values are sent as JSON.
+func EncodeValue(v dgo.Value) string {
+	if s, ok := v.(dgo.String); ok && !needsQuotes(s.GoString()) {
+		return s.GoString()
+	}
+	return string(streamer.MarshalJSON(v, nil))
+}
+
+// needsQuotes returns true if the given string would not be read back verbatim
+func needsQuotes(s string) bool {
+	return s == `` || strings.ContainsAny(s[:1], `{["':=`) || strings.TrimSpace(s) != s || ParseScalar(s) != nil
+}
@@ -35,6 +35,20 @@ type ResolvedConfig interface {
 	DefaultHierarchy() []DataProvider
 
 	// LookupOptions returns the resolved lookup_options value for the given key or nil
-	// if no such options exists.
+	// if no such options exists. An exact match on the key has precedence over a match
+	// using a regular expression key.
 	LookupOptions(key Key) dgo.Map
+
+	// DefaultLookupOptions returns the lookup_options value for the given key that was resolved using
+	// the default_hierarchy, or nil if no such options exists.
+	DefaultLookupOptions(key Key) dgo.Map
+
+	// MatchLookupOptions is like LookupOptions but also returns the regular expression pattern of the
+	// lookup_options key that matched. The pattern is empty when the options were found using an exact
+	// match or when no options were found.
+	MatchLookupOptions(key Key) (dgo.Map, string)
+
+	// MatchDefaultLookupOptions is like DefaultLookupOptions but also returns the regular expression pattern
+	// of the lookup_options key that matched.
+	MatchDefaultLookupOptions(key Key) (dgo.Map, string)
 }
@@ -4,6 +4,14 @@ import (
 	"github.com/lyraproj/dgo/dgo"
 )
 
+// A Source is a value that a DataProvider found at a Location. The Location is nil when the
+// DataProvider has no locations.
+type Source struct {
+	Provider DataProvider
+	Location Location
+	Value    dgo.Value
+}
+
 // A DataProvider performs a lookup using a configured lookup function.
 type DataProvider interface {
 	// Hierarchy returns the entry where this provider was configured
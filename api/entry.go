@@ -4,6 +4,14 @@ import (
 	"github.com/lyraproj/dgo/dgo"
 )
 
+// MergeBehaviorStop is the merge_behavior of a hierarchy entry that is authoritative. Once the entry yields
+// a value, the entries that follow it are not merged in.
+const MergeBehaviorStop = `stop`
+
+// MergeBehaviorSkipPrefix is the prefix of a merge_behavior that excludes a hierarchy entry from merges that
+// use a specific merge strategy, e.g. "skip_for_unique" or "skip_for_deep".
+const MergeBehaviorSkipPrefix = `skip_for_`
+
 // An Entry is a definition an entry in the hierarchy.
 type Entry interface {
 	// Create a copy of this entry for the given Config
@@ -27,6 +35,9 @@ type Entry interface {
 	// Name returns the name
 	Name() string
 
+	// MergeBehavior returns the merge_behavior or an empty string when no such behavior has been declared
+	MergeBehavior() string
+
 	// Resolve resolves this configuration on behalf of the given invocation and defaults entry
 	Resolve(ic Invocation, defaults Entry) Entry
 
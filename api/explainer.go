@@ -13,6 +13,9 @@ type Explainer interface {
 	// AcceptFound accepts information that a value was found for a given key
 	AcceptFound(key interface{}, value dgo.Value)
 
+	// AcceptFoundAt accepts information that a value was found for a given key at a given position in a data file
+	AcceptFoundAt(key interface{}, value dgo.Value, position string)
+
 	// AcceptFoundInDefaults accepts information that a value was found for a given key in the defaults hash
 	AcceptFoundInDefaults(key string, value dgo.Value)
 
@@ -23,6 +26,10 @@ type Explainer interface {
 	// by the top explainer node of type Context "Location"
 	AcceptLocationNotFound()
 
+	// AcceptLookupOptionsPattern accepts information that the lookup_options for the current key was found
+	// using a regular expression key with the given pattern
+	AcceptLookupOptionsPattern(pattern string)
+
 	// AcceptMergeSource accepts information that about as source for merge options such as the lookup_options hash
 	AcceptMergeSource(mergeSource string)
 
@@ -40,6 +47,8 @@ type Explainer interface {
 
 	PushDataProvider(pvd DataProvider)
 
+	PushDefaultHierarchy()
+
 	PushInterpolation(expr string)
 
 	PushInvalidKey(key interface{})
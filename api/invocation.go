@@ -34,6 +34,19 @@ type Invocation interface {
 	// Lookup performs a lookup using the given options
 	Lookup(key Key, options dgo.Map) dgo.Value
 
+	// RecordProvenance calls the producer and returns its result together with the provenance of the value that
+	// was found for the root of the given key during that call. The provenance is nil when no value was found.
+	RecordProvenance(key Key, producer dgo.Producer) (dgo.Value, *Provenance)
+
+	// TakeProvenance returns the provenance of the value that was most recently found while provenance is recorded
+	// and clears it so that it isn't mistaken for the provenance of a value found later. It returns nil when no
+	// provenance is recorded.
+	TakeProvenance() *Provenance
+
+	// SetProvenance sets the provenance of the value that was most recently found. Merge strategies call it with
+	// the provenance of the merged value. It does nothing when no provenance is recorded.
+	SetProvenance(p *Provenance)
+
 	// LookupAndConvertData checks if the lookupOptions assigned to this invocation with SetMergeStrategy also
 	// stipulates that a found value should be converted to a Sensitive. If that is the case, any occurrence of
 	// the found value will be redacted in log statements written during the call of the given
@@ -62,9 +75,16 @@ type Invocation interface {
 	// ReportFound reports that the given value was found using the given key
 	ReportFound(key interface{}, value dgo.Value)
 
+	// ReportFoundAt reports that the given value was found using the given key at the given position in a data file
+	ReportFoundAt(key interface{}, value dgo.Value, position string)
+
 	// ReportMergeResult reports the result of a the current merge operation
 	ReportMergeResult(value dgo.Value)
 
+	// ReportLookupOptionsPattern reports that the lookup_options for the current key was found using a regular
+	// expression key with the given pattern
+	ReportLookupOptionsPattern(pattern string)
+
 	// ReportMergeSource reports the source of the current merge (explicit options or lookup options)
 	ReportMergeSource(source string)
 
@@ -81,6 +101,10 @@ type Invocation interface {
 	// provider again before returning.
 	WithDataProvider(pvd DataProvider, f dgo.Producer) dgo.Value
 
+	// WithDefaultHierarchy pushes a default_hierarchy marker to the explanation stack and calls the producer, then
+	// pops the marker again before returning.
+	WithDefaultHierarchy(f dgo.Producer) dgo.Value
+
 	// WithInterpolation pushes the given expression to the explanation stack and calls the producer, then pops the
 	// expression again before returning.
 	WithInterpolation(expr string, f dgo.Producer) dgo.Value
@@ -123,6 +147,10 @@ type Invocation interface {
 	// how it should report lookup of data (as opposed to lookup of "lookup_options").
 	ForData() Invocation
 
+	// ForDefaultHierarchy returns an Invocation that is adjusted to do lookup of data in a default_hierarchy. The
+	// returned Invocation can be given its own merge strategy without affecting the receiver.
+	ForDefaultHierarchy() Invocation
+
 	// ForLookupOptions returns an Invocation that has adjusted its explainer according to
 	// how it should report lookup of the "lookup_options" key.
 	ForLookupOptions() Invocation
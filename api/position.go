@@ -0,0 +1,26 @@
+package api
+
+import (
+	"fmt"
+)
+
+// A Position is the position of a key in a data file. Line and column numbers start at 1.
+type Position struct {
+	File   string
+	Line   int
+	Column int
+}
+
+// String returns the position in the form "file:line:column"
+func (p *Position) String() string {
+	return fmt.Sprintf(`%s:%d:%d`, p.File, p.Line, p.Column)
+}
+
+// A PositionProvider is a DataProvider that can tell the position of the keys in the data that it provides.
+type PositionProvider interface {
+	DataProvider
+
+	// Position returns the position of the given dot separated key path in the data found at the given
+	// location, or nil if the position is unknown. Array elements are denoted by their index in the path.
+	Position(location Location, path string) *Position
+}
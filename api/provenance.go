@@ -0,0 +1,69 @@
+package api
+
+import "sort"
+
+// A Provenance tells which sources that a found value, and the parts of that value, stem from. It is recorded by the
+// merge strategies while they merge the values found in the hierarchy.
+//
+// A value that stems from one source as a whole has that source in Sources. A hash or an array whose parts stem from
+// different sources has the provenance of each entry in Entries, or of each element in Elements, instead. A value
+// that was produced from several sources in a way that cannot be attributed to its parts, such as by a custom merge
+// strategy or by a convert_to conversion, has all of those sources in Sources.
+type Provenance struct {
+	// Sources are the sources of the value as a whole
+	Sources []*Source
+
+	// Entries are the provenances of the entries of a hash, keyed by the string form of the entry key
+	Entries map[string]*Provenance
+
+	// Elements are the provenances of the elements of an array
+	Elements []*Provenance
+}
+
+// NewProvenance returns the provenance of a value that stems from the given source as a whole
+func NewProvenance(source *Source) *Provenance {
+	return &Provenance{Sources: []*Source{source}}
+}
+
+// AllSources returns the sources of the value and of all its parts without duplicates. The entries of hashes are
+// visited in key order.
+func (p *Provenance) AllSources() []*Source {
+	var all []*Source
+	var collect func(p *Provenance)
+	collect = func(p *Provenance) {
+		if p == nil {
+			return
+		}
+	nextSource:
+		for _, s := range p.Sources {
+			for _, a := range all {
+				if a == s {
+					continue nextSource
+				}
+			}
+			all = append(all, s)
+		}
+		keys := make([]string, 0, len(p.Entries))
+		for k := range p.Entries {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			collect(p.Entries[k])
+		}
+		for _, e := range p.Elements {
+			collect(e)
+		}
+	}
+	collect(p)
+	return all
+}
+
+// Collapse returns a provenance that has all sources of the given provenance as the sources of the value as a whole.
+// It returns nil when the given provenance is nil.
+func (p *Provenance) Collapse() *Provenance {
+	if p == nil || len(p.Entries) == 0 && len(p.Elements) == 0 {
+		return p
+	}
+	return &Provenance{Sources: p.AllSources()}
+}
@@ -48,6 +48,11 @@ type Session interface {
 	// TopProviderCache returns the shared provider cache used by all lookups
 	TopProviderCache() *sync.Map
 
+	// Reload replaces the shared cache and the provider cache with empty caches so that configurations and data are
+	// read again. The replacement is atomic. Invocations created after the call use the new caches while invocations
+	// that are in progress continue to use the caches that they started with.
+	Reload()
+
 	// Get returns a session variable, or nil if no such variable exists. Session variables
 	// are used internally by Hiera and should not be confused with Scope variables.
 	Get(key string) interface{}
@@ -3,11 +3,13 @@ package cli
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/lyraproj/dgo/vf"
 	"github.com/lyraproj/hiera/api"
 	"github.com/lyraproj/hiera/config"
 	"github.com/lyraproj/hiera/hiera"
+	"github.com/lyraproj/hiera/merge"
 	"github.com/lyraproj/hiera/provider"
 	sdk "github.com/lyraproj/hierasdk/hiera"
 	"github.com/spf13/cobra"
@@ -73,6 +75,7 @@ var (
 	logLevel   string
 	configPath string
 	dialect    string
+	positions  bool
 )
 
 // NewCommand creates the hiera Command
@@ -90,7 +93,13 @@ func NewCommand() *cobra.Command {
 	flags.StringVar(&logLevel, `loglevel`, `error`,
 		`error/warn/info/debug`)
 	flags.StringVar(&cmdOpts.Merge, `merge`, `first`,
-		`first/unique/hash/deep`)
+		strings.Join(merge.Names(), `/`))
+	flags.StringVar(&cmdOpts.KnockoutPrefix, `knock-out-prefix`, ``,
+		`a prefix that indicates that a value should be removed from the result of a deep merge`)
+	flags.BoolVar(&cmdOpts.SortMergedArrays, `sort-merged-arrays`, false,
+		`sort all arrays that are merged by a deep merge`)
+	flags.BoolVar(&cmdOpts.MergeHashArrays, `merge-hash-arrays`, false,
+		`deep merge arrays of hashes by merging the hashes at the same index`)
 	flags.StringVar(&configPath, `config`, ``,
 		`path to the hiera config file. Overrides <current directory>/`+config.FileName)
 	flags.Var(&dflt, `default`,
@@ -105,6 +114,10 @@ func NewCommand() *cobra.Command {
 		`Explain the details of how the lookup was performed and where the final value came from`)
 	flags.BoolVar(&cmdOpts.ExplainOptions, `explain-options`, false,
 		`Explain whether a lookup_options hash affects this lookup, and how that hash was assembled`)
+	flags.BoolVar(&positions, `positions`, false,
+		`Retain the line and column of keys in YAML and JSON data files and show them in explanations and errors`)
+	flags.BoolVar(&cmdOpts.Provenance, `provenance`, false,
+		`Output the value together with the hierarchy level and location that each part of the value was found in`)
 	flags.StringArrayVar(&cmdOpts.VarPaths, `vars`, nil,
 		`path to a JSON or YAML file that contains key-value mappings to become variables for this lookup`)
 	flags.StringArrayVar(&cmdOpts.Variables, `var`, nil,
@@ -123,6 +136,9 @@ func cmdLookup(cmd *cobra.Command, args []string) error {
 	cmdOpts.Default = dflt.StringPointer()
 	cfgOpts := vf.MutableMap()
 	cfgOpts.Put(api.HieraDialect, dialect)
+	if positions {
+		cfgOpts.Put(api.HieraPositions, true)
+	}
 	cfgOpts.Put(
 		provider.LookupKeyFunctions, []sdk.LookupKey{provider.ConfigLookupKey, provider.Environment})
 
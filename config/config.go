@@ -51,7 +51,8 @@ const definitions = `{
 	  globs?:[1]rstring,
 	  uri?:rstring,
 	  uris?:[1]rstring,
-	  mapped_paths?:[3,3]rstring
+	  mapped_paths?:[3,3]rstring,
+	  merge_behavior?:/\A(stop|skip_for_[a-z][0-9a-z_]*)\z/
 	}
 }`
 
@@ -208,6 +209,8 @@ func (hc *hieraCfg) createEntry(name string, entryHash dgo.Map) api.Entry {
 			entry.pluginDir = v.String()
 		case ks == `pluginfile`:
 			entry.pluginFile = v.String()
+		case ks == `merge_behavior`:
+			entry.mergeBehavior = v.String()
 		case util.ContainsString(LocationKeys, ks):
 			if entry.locations != nil {
 				panic(fmt.Errorf(`only one of %s can be defined in hierarchy '%s'`, strings.Join(LocationKeys, `, `), name))
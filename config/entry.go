@@ -14,14 +14,15 @@ import (
 
 type (
 	entry struct {
-		cfg        *hieraCfg
-		dataDir    string
-		pluginDir  string
-		pluginFile string
-		options    dgo.Map
-		function   api.Function
-		name       string
-		locations  []api.Location
+		cfg           *hieraCfg
+		dataDir       string
+		pluginDir     string
+		pluginFile    string
+		options       dgo.Map
+		function      api.Function
+		name          string
+		mergeBehavior string
+		locations     []api.Location
 	}
 )
 
@@ -89,6 +90,10 @@ func (e *entry) Name() string {
 	return e.name
 }
 
+func (e *entry) MergeBehavior() string {
+	return e.mergeBehavior
+}
+
 func (e *entry) Locations() []api.Location {
 	return e.locations
 }
@@ -0,0 +1,115 @@
+package examples_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lyraproj/dgo/vf"
+	"github.com/lyraproj/hiera/hieraserver/acl"
+)
+
+const aclRules = `
+rules:
+  - tokens: [app-token]
+    keys: [app.*, common]
+    merge: [first, deep]
+  - subjects: [web01.example.com]
+    keys: [web]
+    sensitive: true
+  - subjects: ['CN=web03.example.com,O=Example']
+    keys: [web]
+  - subjects: ['*']
+    keys: [common]
+`
+
+func tokenRequest(token string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, `/lookup/x`, nil)
+	r.Header.Set(`Authorization`, `Bearer `+token)
+	return r
+}
+
+func certRequest(cn string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, `/lookup/x`, nil)
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: cn, Organization: []string{`Example`}}}
+	r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+	return r
+}
+
+// TestACL_authorize shows how the rules of an access control list grant clients access to keys based on their
+// bearer token or verified client certificate.
+func TestACL_authorize(t *testing.T) {
+	a, err := acl.Parse([]byte(aclRules))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		r       *http.Request
+		key     string
+		merge   string
+		granted bool
+	}{
+		{`token, granted key`, tokenRequest(`app-token`), `app.db`, ``, true},
+		{`token, granted nested key`, tokenRequest(`app-token`), `app.db.port`, `deep`, true},
+		{`token, parent of pattern`, tokenRequest(`app-token`), `app`, ``, false},
+		{`token, merge not granted`, tokenRequest(`app-token`), `app.db`, `unique`, false},
+		{`token, key not granted`, tokenRequest(`app-token`), `web`, ``, false},
+		{`unknown token`, tokenRequest(`other`), `common`, ``, false},
+		{`no credentials`, httptest.NewRequest(http.MethodGet, `/lookup/x`, nil), `common`, ``, false},
+		{`cert by common name`, certRequest(`web01.example.com`), `web.port`, `hash`, true},
+		{`cert by distinguished name`, certRequest(`web03.example.com`), `web`, ``, true},
+		{`any verified cert`, certRequest(`web02.example.com`), `common`, ``, true},
+		{`cert, key not granted`, certRequest(`web02.example.com`), `web`, ``, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := a.Authorize(tt.r, tt.key, tt.merge)
+			if tt.granted && err != nil {
+				t.Errorf(`expected access to be granted, got %s`, err)
+			} else if !tt.granted && err == nil {
+				t.Error(`expected access to be denied`)
+			}
+		})
+	}
+}
+
+// TestACL_sensitive shows that access to Sensitive values must be granted explicitly.
+func TestACL_sensitive(t *testing.T) {
+	a, err := acl.Parse([]byte(aclRules))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = a.AuthorizeSensitive(certRequest(`web01.example.com`), `web.password`); err != nil {
+		t.Error(err)
+	}
+	if err = a.AuthorizeSensitive(tokenRequest(`app-token`), `app.password`); err == nil {
+		t.Error(`expected access to sensitive value to be denied`)
+	}
+
+	if acl.ContainsSensitive(vf.Map(`user`, `bob`, `ports`, vf.Values(80, 443))) {
+		t.Error(`plain value reported as sensitive`)
+	}
+	if !acl.ContainsSensitive(vf.Map(`user`, `bob`, `secrets`, vf.Values(vf.Sensitive(`pw`)))) {
+		t.Error(`nested sensitive value not found`)
+	}
+}
+
+// TestACL_parseErrors shows the errors that are returned for rules that can never grant anything or that have invalid
+// key patterns or endpoints.
+func TestACL_parseErrors(t *testing.T) {
+	tests := map[string]string{
+		`rules: [{keys: [a]}]`:                   `rule 1 has neither subjects nor tokens`,
+		`rules: [{tokens: [t]}]`:                 `rule 1 has neither keys nor endpoints`,
+		`rules: [{tokens: [t], keys: ['a[']}]`:   `rule 1 has an invalid key pattern 'a[': syntax error in pattern`,
+		`rules: [{tokens: [t], endpoints: [x]}]`: `rule 1 has an unknown endpoint 'x'`,
+	}
+	for rules, expected := range tests {
+		if _, err := acl.Parse([]byte(rules)); err == nil || err.Error() != expected {
+			t.Errorf(`expected error %q, got %v`, expected, err)
+		}
+	}
+}
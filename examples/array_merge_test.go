@@ -0,0 +1,66 @@
+package examples_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lyraproj/dgo/vf"
+	"github.com/lyraproj/hiera/api"
+	"github.com/lyraproj/hiera/hiera"
+	"github.com/lyraproj/hiera/provider"
+)
+
+// TestMerge_arrayMergeUnique shows how the array_merge option of the unique strategy retains duplicates and
+// controls the order of the merged elements.
+func TestMerge_arrayMergeUnique(t *testing.T) {
+	configOptions := map[string]string{api.HieraConfig: `testdata/array_merge.yaml`}
+	hiera.DoWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) {
+		// prepend places the elements of the higher priority level first
+		result := hiera.Lookup(hs.Invocation(nil, nil), `paths`, nil, nil)
+		if !vf.Strings(`/opt/app/bin`, `/usr/bin`, `/usr/local/bin`, `/usr/bin`).Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+
+		// append places the elements of the higher priority level last
+		result = hiera.Lookup(hs.Invocation(nil, nil), `search`, nil, nil)
+		if !vf.Strings(`two`, `three`, `one`, `two`).Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+
+		// replace lets the higher priority level replace the array
+		result = hiera.Lookup(hs.Invocation(nil, nil), `search`, nil,
+			map[string]interface{}{`merge`: map[string]string{`strategy`: `unique`, `array_merge`: `replace`}})
+		if !vf.Strings(`one`, `two`).Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+	})
+}
+
+// TestMerge_arrayMergeDeep shows how the array_merge option of the deep strategy can target arrays at nested key
+// paths. Arrays at paths that are not listed use the default union policy.
+func TestMerge_arrayMergeDeep(t *testing.T) {
+	configOptions := map[string]string{api.HieraConfig: `testdata/array_merge.yaml`}
+	hiera.DoWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) {
+		result := hiera.Lookup(hs.Invocation(nil, nil), `config`, nil, nil)
+		expected := vf.Map(
+			`servers`, vf.Strings(`a.example.com`),
+			`plugins`, vf.Map(`enabled`, vf.Strings(`log`, `metrics`, `auth`, `log`)),
+			`tags`, vf.Strings(`x`, `y`))
+		if !expected.Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+	})
+}
+
+// TestMerge_arrayMergeInvalid shows the error that is produced when the array_merge option is invalid.
+func TestMerge_arrayMergeInvalid(t *testing.T) {
+	configOptions := map[string]string{api.HieraConfig: `testdata/array_merge.yaml`}
+	err := hiera.TryWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) error {
+		hiera.Lookup(hs.Invocation(nil, nil), `bogus`, nil, nil)
+		return nil
+	})
+	expected := `invalid array_merge policy 'shuffle'. Expected one of 'append', 'prepend', 'replace', or 'union'`
+	if err == nil || err.Error() != expected {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
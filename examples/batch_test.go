@@ -0,0 +1,105 @@
+package examples_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/lyraproj/dgo/dgo"
+	"github.com/lyraproj/dgo/vf"
+	"github.com/lyraproj/hiera/api"
+	"github.com/lyraproj/hiera/hiera"
+	"github.com/lyraproj/hiera/hieraserver/router"
+	"github.com/lyraproj/hiera/provider"
+)
+
+// TestLookupBatch shows how several keys are looked up using one request. The scope of the request can contain
+// nested maps and each key gets its own entry in the result.
+func TestLookupBatch(t *testing.T) {
+	req, err := hiera.ParseBatchRequest([]byte(`{
+		"keys": ["packages", "port", "banner", "service_provider", "missing", "timeout"],
+		"scope": {"hostname": "web01", "os": {"family": "Debian"}},
+		"merge": "deep",
+		"type": "{packages: []string, port: int, banner: string, service_provider: string, timeout: int}",
+		"defaults": {"timeout": 30}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	configOptions := vf.Map(api.HieraConfig, `testdata/batch.yaml`)
+	hiera.DoWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) {
+		// Server wide variables are overridden by the scope of the request
+		opts := hiera.CommandOptions{Variables: []string{`hostname=default`}}
+		result := hiera.LookupBatch(hs, &opts, req)
+		expected := vf.Map(
+			`packages`, vf.Map(`value`, vf.Strings(`apt-transport-https`, `curl`)),
+			`port`, vf.Map(`value`, 8080),
+			`banner`, vf.Map(`value`, `Welcome to web01`),
+			`service_provider`, vf.Map(`value`, `systemd`),
+			`missing`, vf.Map(`not_found`, true),
+			`timeout`, vf.Map(`value`, 30))
+		if !expected.Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+	})
+}
+
+// TestLookupBatch_errors shows that an error only affects the entry of the key that caused it.
+func TestLookupBatch_errors(t *testing.T) {
+	req, err := hiera.ParseBatchRequest([]byte(`{"keys": ["port", "banner"], "type": "{port: map[string]int, banner: string}"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	configOptions := vf.Map(api.HieraConfig, `testdata/batch.yaml`)
+	hiera.DoWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) {
+		result := hiera.LookupBatch(hs, &hiera.CommandOptions{}, req)
+		if _, ok := result.Get(`port`).(dgo.Map).Get(`error`).(dgo.String); !ok {
+			t.Fatalf("expected an error for port, got %v", result.Get(`port`))
+		}
+		if !vf.Map(`value`, `Welcome to `).Equals(result.Get(`banner`)) {
+			t.Fatalf("unexpected result %v", result.Get(`banner`))
+		}
+	})
+}
+
+func TestParseBatchRequest_invalid(t *testing.T) {
+	for _, body := range []string{`[]`, `{}`, `{"keys": "a"}`, `{"keys": ["a"], "merge": 1}`, `{"keys": ["a"], "scop": {}}`} {
+		if _, err := hiera.ParseBatchRequest([]byte(body)); err == nil {
+			t.Fatalf("expected an error for %s", body)
+		}
+	}
+}
+
+// TestLookupBatch_handler shows how the router responds to batch requests that are posted to the /lookup endpoint.
+func TestLookupBatch_handler(t *testing.T) {
+	cfg := &router.Config{Options: hiera.CommandOptions{Variables: []string{`hostname=default`}}}
+	withRouter(t, `testdata/batch.yaml`, cfg, func(h http.Handler) {
+		w := serve(h, `POST`, `/lookup`, `{"keys": ["banner", "service_provider", "missing"], "scope": {"os": {"family": "Debian"}}}`, ``)
+		assertResponse(t, w, http.StatusOK,
+			`{"banner":{"value":"Welcome to default"},"service_provider":{"value":"systemd"},"missing":{"not_found":true}}`)
+		if ct := w.Header().Get(`Content-Type`); ct != `application/json` {
+			t.Fatalf("unexpected content type %s", ct)
+		}
+
+		// A failing key is reported in its entry and doesn't fail the request
+		w = serve(h, `POST`, `/lookup`, `{"keys": ["port"], "type": "{port: map[string]int}"}`, ``)
+		assertResponse(t, w, http.StatusOK, ``)
+		if !strings.HasPrefix(w.Body.String(), `{"port":{"error":`) {
+			t.Fatalf("expected an error entry, got %s", w.Body.String())
+		}
+		assertResponse(t, serve(h, `POST`, `/lookup`, `{"keys": ["port"`, ``), http.StatusBadRequest, ``)
+		assertResponse(t, serve(h, `POST`, `/lookup`, `{"keys": ["port"], "scop": {}}`, ``),
+			http.StatusBadRequest, `unknown batch request entry 'scop'`)
+		assertResponse(t, serve(h, `POST`, `/lookup`, `{"keys": []}`, ``), http.StatusBadRequest, `batch request has no keys`)
+		assertResponse(t, serve(h, `POST`, `/lookup`, `{"keys": ["port"], "type": "string"}`, ``),
+			http.StatusBadRequest, `type must be a map`)
+
+		w = serve(h, `GET`, `/lookup`, ``, ``)
+		assertResponse(t, w, http.StatusMethodNotAllowed, ``)
+		if allow := w.Header().Get(`Allow`); allow != `POST` {
+			t.Fatalf("unexpected Allow header %s", allow)
+		}
+	})
+}
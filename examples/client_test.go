@@ -0,0 +1,112 @@
+package examples_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/lyraproj/dgo/vf"
+	"github.com/lyraproj/hiera/hieraserver/client"
+)
+
+// TestClient shows how the client package performs lookups using the REST API of a Hiera server.
+func TestClient(t *testing.T) {
+	ts := remoteHieraServer(`testdata/federation_remote.yaml`)
+	defer ts.Close()
+
+	c, err := client.New(client.Config{BaseURL: ts.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	opts := &client.Options{Merge: `deep`, Variables: map[string]interface{}{`team`: `payments`}}
+
+	// Lookup returns a dgo.Value
+	result, err := c.Lookup(ctx, `tags`, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !vf.Map(`remote`, true, `team`, `payments`).Equals(result) {
+		t.Fatalf("unexpected result %v", result)
+	}
+
+	// LookupInto decodes into a Go value
+	var tags struct {
+		Remote bool   `json:"remote"`
+		Team   string `json:"team"`
+	}
+	if err = c.LookupInto(ctx, `tags`, opts, &tags); err != nil {
+		t.Fatal(err)
+	}
+	if !tags.Remote || tags.Team != `payments` {
+		t.Fatalf("unexpected result %v", tags)
+	}
+
+	// The default is returned when no value is found
+	result, err = c.Lookup(ctx, `missing`, &client.Options{Default: map[string]interface{}{`a`: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !vf.Map(`a`, 1).Equals(result) {
+		t.Fatalf("unexpected result %v", result)
+	}
+}
+
+// TestClient_typedValues shows that a default value and variables that are not strings are passed to the server as
+// values of their own type.
+func TestClient_typedValues(t *testing.T) {
+	ts := remoteHieraServer(`testdata/scope.yaml`)
+	defer ts.Close()
+
+	c, err := client.New(client.Config{BaseURL: ts.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	for _, dflt := range []interface{}{5, 2.5, true, `5`, `true`, ` 5`} {
+		result, err := c.Lookup(ctx, `missing`, &client.Options{Default: dflt})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !vf.Value(dflt).Equals(result) {
+			t.Fatalf("unexpected result %#v for default %#v", result, dflt)
+		}
+	}
+
+	vars := map[string]interface{}{`count`: 3, `ratio`: 0.5, `enabled`: false, `zip`: `0123`, `version`: `1.10`, `id`: `42`}
+	for k, v := range vars {
+		result, err := c.Lookup(ctx, k, &client.Options{Variables: vars})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !vf.Value(v).Equals(result) {
+			t.Fatalf("unexpected result %#v for variable %s", result, k)
+		}
+	}
+}
+
+// TestClient_errors shows the errors returned when no value is found or when the lookup fails.
+func TestClient_errors(t *testing.T) {
+	ts := remoteHieraServer(`testdata/federation_remote.yaml`)
+	defer ts.Close()
+
+	c, err := client.New(client.Config{BaseURL: ts.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	_, err = c.Lookup(ctx, `missing`, nil)
+	var nf *client.NotFoundError
+	if !errors.As(err, &nf) || nf.Key != `missing` {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	_, err = c.Lookup(ctx, `owner`, &client.Options{Type: `int`})
+	var se *client.ServerError
+	if !errors.As(err, &se) || se.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
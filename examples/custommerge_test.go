@@ -0,0 +1,119 @@
+package examples_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/lyraproj/dgo/dgo"
+	"github.com/lyraproj/dgo/streamer"
+	"github.com/lyraproj/dgo/vf"
+	"github.com/lyraproj/hiera/api"
+	"github.com/lyraproj/hiera/explain"
+	"github.com/lyraproj/hiera/hiera"
+	"github.com/lyraproj/hiera/merge"
+	"github.com/lyraproj/hiera/provider"
+)
+
+// Custom merge strategies are registered once, typically from an init function, and are then available
+// everywhere a built in strategy can be used.
+func init() {
+	// The "last" strategy returns the value found in the least specific level of the hierarchy.
+	merge.Register(`last`, `last found strategy`,
+		func(_, b dgo.Value, _ dgo.Map) dgo.Value {
+			return b
+		}, nil)
+
+	// The "append_arrays" strategy concatenates arrays without removing duplicates. Single values are converted
+	// into arrays.
+	merge.Register(`append_arrays`, `append arrays strategy`,
+		func(a, b dgo.Value, _ dgo.Map) dgo.Value {
+			return a.(dgo.Array).WithAll(toArray(b))
+		},
+		func(v dgo.Value, _ dgo.Map) dgo.Value {
+			return toArray(v)
+		})
+
+	// The "upcase_first" strategy returns the first value found and upper cases it when it is a string. The
+	// convert function returns nil for other values so that they are used as is.
+	merge.Register(`upcase_first`, `upcase first found strategy`,
+		func(a, _ dgo.Value, _ dgo.Map) dgo.Value {
+			return a
+		},
+		func(v dgo.Value, _ dgo.Map) dgo.Value {
+			if s, ok := v.(dgo.String); ok {
+				return vf.String(strings.ToUpper(s.GoString()))
+			}
+			return nil
+		})
+}
+
+func toArray(v dgo.Value) dgo.Array {
+	if a, ok := v.(dgo.Array); ok {
+		return a
+	}
+	return vf.Values(v)
+}
+
+// TestMerge_registered shows how a registered merge strategy can be used from the lookup_options hash
+// and as an explicit merge option.
+func TestMerge_registered(t *testing.T) {
+	configOptions := map[string]string{api.HieraConfig: `testdata/custom_merge.yaml`}
+	hiera.DoWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) {
+		// lookup_options declares that "list" uses the "append_arrays" strategy
+		result := hiera.Lookup(hs.Invocation(nil, nil), `list`, nil, nil)
+		if !vf.Strings(`a`, `b`, `b`, `c`).Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+
+		// lookup_options declares that "greeting" uses the "last" strategy
+		result = hiera.Lookup(hs.Invocation(nil, nil), `greeting`, nil, nil)
+		if result == nil || `goodbye` != result.String() {
+			t.Fatalf("unexpected result %v", result)
+		}
+
+		// explicit merge option
+		result = hiera.Lookup(hs.Invocation(nil, nil), `greeting`, nil, map[string]string{`merge`: `append_arrays`})
+		if !vf.Strings(`hello`, `goodbye`).Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+	})
+}
+
+// TestMerge_registeredNoConversion shows that a value is used as is when the convert function of a registered merge
+// strategy returns nil.
+func TestMerge_registeredNoConversion(t *testing.T) {
+	configOptions := map[string]string{api.HieraConfig: `testdata/custom_merge.yaml`}
+	hiera.DoWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) {
+		options := map[string]string{`merge`: `upcase_first`}
+		result := hiera.Lookup(hs.Invocation(nil, nil), `greeting`, nil, options)
+		if result == nil || `HELLO` != result.String() {
+			t.Fatalf("unexpected result %v", result)
+		}
+
+		result = hiera.Lookup(hs.Invocation(nil, nil), `list`, nil, options)
+		if !vf.Strings(`a`, `b`).Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+	})
+}
+
+// TestMerge_registeredExplain shows that an explanation that includes a registered merge strategy can be
+// serialized and deserialized.
+func TestMerge_registeredExplain(t *testing.T) {
+	configOptions := map[string]string{api.HieraConfig: `testdata/custom_merge.yaml`}
+	hiera.DoWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) {
+		explainer := explain.NewExplainer(false, false)
+		hiera.Lookup(hs.Invocation(nil, explainer), `list`, nil, nil)
+
+		expectedExplanation := explainer.String()
+		if !strings.Contains(expectedExplanation, `Merge strategy "append arrays strategy"`) {
+			t.Fatalf("unexpected explanation `%s`", expectedExplanation)
+		}
+		ex := streamer.UnmarshalJSON(streamer.MarshalJSON(explainer, nil), nil)
+		actualExplanation := ex.String()
+		if expectedExplanation != actualExplanation {
+			t.Fatalf("expected explanation `%s` does not match actual `%s`", expectedExplanation, actualExplanation)
+		}
+	})
+}
@@ -0,0 +1,139 @@
+package examples_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/lyraproj/dgo/vf"
+	"github.com/lyraproj/hiera/api"
+	"github.com/lyraproj/hiera/explain"
+	"github.com/lyraproj/hiera/hiera"
+	"github.com/lyraproj/hiera/provider"
+)
+
+/*
+ The tests in this file use a configuration that has both a "hierarchy" and a "default_hierarchy". The
+ default_hierarchy is only consulted when no value is found in the hierarchy. When that happens, the
+ lookup_options declared in the default_hierarchy determine how the values found there are merged.
+*/
+
+// TestDefaultHierarchy_notConsulted shows that the default_hierarchy is not consulted when a value is
+// found in the hierarchy, and that lookup_options declared in the default_hierarchy do not affect the
+// lookup in the hierarchy.
+func TestDefaultHierarchy_notConsulted(t *testing.T) {
+	configOptions := map[string]string{api.HieraConfig: `testdata/default_hierarchy.yaml`}
+	hiera.DoWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) {
+		// m.b only exists in the second level of the hierarchy and in the default_hierarchy. The deep merge declared
+		// for "m" in the default_hierarchy is not used so no merge takes place.
+		result := hiera.Lookup(hs.Invocation(nil, nil), `m.b`, nil, nil)
+		if result != nil {
+			t.Fatalf("unexpected result %v", result)
+		}
+
+		result = hiera.Lookup(hs.Invocation(nil, nil), `m`, nil, nil)
+		if !vf.Value(map[string]string{`a`: `first value of a`, `c`: `first value of c`}).Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+	})
+}
+
+// TestDefaultHierarchy_hash shows that a hash merge declared in the default_hierarchy lookup_options is used
+// when the lookup falls through to the default_hierarchy.
+func TestDefaultHierarchy_hash(t *testing.T) {
+	configOptions := map[string]string{api.HieraConfig: `testdata/default_hierarchy.yaml`}
+	hiera.DoWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) {
+		result := hiera.Lookup(hs.Invocation(nil, nil), `dh`, nil, nil)
+		expected := vf.Map(
+			`a`, `first value of a`,
+			`b`, `second value of b`,
+			`c`, vf.Map(`x`, `first value of c.x`))
+		if !expected.Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+	})
+}
+
+// TestDefaultHierarchy_deep shows that a deep merge declared in the default_hierarchy lookup_options is used
+// when the lookup falls through to the default_hierarchy.
+func TestDefaultHierarchy_deep(t *testing.T) {
+	configOptions := map[string]string{api.HieraConfig: `testdata/default_hierarchy.yaml`}
+	hiera.DoWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) {
+		result := hiera.Lookup(hs.Invocation(nil, nil), `dd`, nil, nil)
+		expected := vf.Map(
+			`a`, `first value of a`,
+			`b`, `second value of b`,
+			`c`, vf.Map(`x`, `first value of c.x`, `y`, `second value of c.y`))
+		if !expected.Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+	})
+}
+
+// TestDefaultHierarchy_unique shows that a unique merge declared in the default_hierarchy lookup_options is used
+// when the lookup falls through to the default_hierarchy.
+func TestDefaultHierarchy_unique(t *testing.T) {
+	configOptions := map[string]string{api.HieraConfig: `testdata/default_hierarchy.yaml`}
+	hiera.DoWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) {
+		result := hiera.Lookup(hs.Invocation(nil, nil), `du`, nil, nil)
+		if !vf.Strings(`a`, `b`, `c`).Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+
+		// An explicit merge option has precedence over the default_hierarchy lookup_options
+		result = hiera.Lookup(hs.Invocation(nil, nil), `du`, nil, map[string]string{`merge`: `first`})
+		if !vf.Strings(`a`, `b`).Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+	})
+}
+
+// TestDefaultHierarchy_explain shows how the explainer reports that the lookup fell through to the
+// default_hierarchy.
+func TestDefaultHierarchy_explain(t *testing.T) {
+	configOptions := map[string]string{api.HieraConfig: `testdata/default_hierarchy.yaml`}
+	hiera.DoWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) {
+		explainer := explain.NewExplainer(false, false)
+		result := hiera.Lookup(hs.Invocation(nil, explainer), `du`, nil, nil)
+		if !vf.Strings(`a`, `b`, `c`).Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+
+		expectedExplanation := filepath.FromSlash(`Searching for "du"
+  data_hash function 'yaml_data'
+    Path "testdata/data/merge1.yaml"
+      Original path: "merge1.yaml"
+      No such key: "du"
+  data_hash function 'yaml_data'
+    Path "testdata/data/merge2.yaml"
+      Original path: "merge2.yaml"
+      No such key: "du"
+  Searching default_hierarchy
+    Using merge options from "lookup_options" hash
+    Merge strategy "unique merge strategy"
+      data_hash function 'yaml_data'
+        Path "testdata/data/defaults/first.yaml"
+          Original path: "defaults/first.yaml"
+          Found key: "du" value: {
+            "a",
+            "b"
+          }
+      data_hash function 'yaml_data'
+        Path "testdata/data/defaults/second.yaml"
+          Original path: "defaults/second.yaml"
+          Found key: "du" value: {
+            "b",
+            "c"
+          }
+      Merged result: {
+        "a",
+        "b",
+        "c"
+      }`)
+
+		actualExplanation := explainer.String()
+		if expectedExplanation != actualExplanation {
+			t.Fatalf("expected explanation `%s` does not match actual `%s`", expectedExplanation, actualExplanation)
+		}
+	})
+}
@@ -0,0 +1,52 @@
+package examples_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lyraproj/dgo/dgo"
+	"github.com/lyraproj/dgo/vf"
+	"github.com/lyraproj/hiera/api"
+	"github.com/lyraproj/hiera/hiera"
+	"github.com/lyraproj/hiera/provider"
+)
+
+// TestDirectoryData shows how the directory_data function uses the files of a directory, such as a mounted
+// Kubernetes ConfigMap or the Docker secrets directory, as keys.
+func TestDirectoryData(t *testing.T) {
+	configOptions := map[string]string{api.HieraConfig: `testdata/directory.yaml`}
+	hiera.DoWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) {
+		// The trailing newline is removed
+		result := hiera.Lookup(hs.Invocation(nil, nil), `greeting`, nil, nil)
+		if !vf.String(`hello`).Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+
+		// A subdirectory is a hash. Hidden files are ignored
+		result = hiera.Lookup(hs.Invocation(nil, nil), `db.host`, nil, nil)
+		if !vf.String(`db.example.com`).Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+		result = hiera.Lookup(hs.Invocation(nil, nil), `db`, nil, nil)
+		if !vf.Map(`host`, `db.example.com`, `port`, `5432`).Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+
+		// YAML and JSON files are decoded because the "decode" option is true
+		result = hiera.Lookup(hs.Invocation(nil, nil), `settings.log.level`, nil, nil)
+		if !vf.String(`debug`).Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+		result = hiera.Lookup(hs.Invocation(nil, nil), `features.enabled`, nil, nil)
+		if !vf.True.Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+
+		// Values from the secrets directory are sensitive
+		result = hiera.Lookup(hs.Invocation(nil, nil), `db_password`, nil, nil)
+		s, ok := result.(dgo.Sensitive)
+		if !ok || !vf.String(`s3cr3t`).Equals(s.Unwrap()) {
+			t.Fatalf("unexpected result %v", result)
+		}
+	})
+}
@@ -0,0 +1,69 @@
+package examples_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/lyraproj/dgo/vf"
+	"github.com/lyraproj/hiera/api"
+	"github.com/lyraproj/hiera/hiera"
+	"github.com/lyraproj/hiera/provider"
+)
+
+// TestEnvironment_prefix shows how the "env_prefix" option of the environment lookup_key maps prefixed environment
+// variables to nested keys so that they can override values from other levels of the hierarchy. Values that look like
+// numbers are only converted when they retain their formatting, so the zip code "02134" and the version "1.10" remain
+// strings.
+func TestEnvironment_prefix(t *testing.T) {
+	for n, v := range map[string]string{
+		`HIERATEST__DB__PORT`: `5432`,
+		`HIERATEST__DEBUG`:    `true`,
+		`HIERATEST__DB__USER`: `admin`,
+		`HIERATEST__ZIP`:      `02134`,
+		`HIERATEST__VERSION`:  `1.10`,
+	} {
+		if err := os.Setenv(n, v); err != nil {
+			t.Fatal(err)
+		}
+		defer func(n string) { _ = os.Unsetenv(n) }(n)
+	}
+
+	configOptions := map[string]string{api.HieraConfig: `testdata/environment.yaml`}
+	hiera.DoWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) {
+		result := hiera.Lookup(hs.Invocation(nil, nil), `hieratest.db.port`, nil, nil)
+		if !vf.Value(5432).Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+
+		result = hiera.Lookup(hs.Invocation(nil, nil), `hieratest`, nil, map[string]string{`merge`: `deep`})
+		expected := vf.Map(
+			`db`, vf.Map(`host`, `localhost`, `port`, 5432, `user`, `admin`),
+			`debug`, true,
+			`zip`, `02134`,
+			`version`, `1.10`)
+		if !expected.Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+	})
+}
+
+// TestDotenvData shows how the dotenv_data function reads the variables of a .env file. All values are strings.
+func TestDotenvData(t *testing.T) {
+	configOptions := map[string]string{api.HieraConfig: `testdata/environment.yaml`}
+	hiera.DoWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) {
+		expected := vf.Strings(`orders`, `http://localhost:8080/orders`, "hello\nworld", `no \n escapes`, ``, `value`, `02134`, `1.10`)
+		result := vf.Values(
+			hiera.Lookup(hs.Invocation(nil, nil), `SERVICE_NAME`, nil, nil),
+			hiera.Lookup(hs.Invocation(nil, nil), `SERVICE_URL`, nil, nil),
+			hiera.Lookup(hs.Invocation(nil, nil), `GREETING`, nil, nil),
+			hiera.Lookup(hs.Invocation(nil, nil), `LITERAL`, nil, nil),
+			hiera.Lookup(hs.Invocation(nil, nil), `EMPTY`, nil, nil),
+			hiera.Lookup(hs.Invocation(nil, nil), `COMMENTED`, nil, nil),
+			hiera.Lookup(hs.Invocation(nil, nil), `ZIP`, nil, nil),
+			hiera.Lookup(hs.Invocation(nil, nil), `VERSION`, nil, nil))
+		if !expected.Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+	})
+}
@@ -0,0 +1,240 @@
+package examples_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lyraproj/hiera/api"
+	"github.com/lyraproj/hiera/hiera"
+	"github.com/lyraproj/hiera/hieraserver/environments"
+	"github.com/lyraproj/hiera/hieraserver/router"
+)
+
+// TestPool shows how a pool creates one session per environment when the environment is first used.
+func TestPool(t *testing.T) {
+	pool := environments.NewPool(context.Background(), `testdata/environments`, nil, nil, 0)
+	defer func() {
+		_ = pool.Close()
+	}()
+
+	lookup := func(env string) (v string) {
+		t.Helper()
+		if err := pool.Do(env, func(s api.Session) {
+			v = hiera.Lookup(s.Invocation(nil, nil), `log_level`, nil, nil).String()
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	if names := pool.Names(); len(names) != 0 {
+		t.Fatalf("unexpected sessions %v", names)
+	}
+	if v := lookup(`staging`); v != `debug` {
+		t.Fatalf("unexpected result %s", v)
+	}
+	if v := lookup(`production`); v != `warn` {
+		t.Fatalf("unexpected result %s", v)
+	}
+	if names := pool.Names(); !reflect.DeepEqual(names, []string{`production`, `staging`}) {
+		t.Fatalf("unexpected sessions %v", names)
+	}
+}
+
+// TestPool_notFound shows that only directories with a hiera.yaml are environments and that names that could appoint
+// something outside of the environments directory are rejected.
+func TestPool_notFound(t *testing.T) {
+	pool := environments.NewPool(context.Background(), `testdata/environments`, nil, nil, 0)
+	defer func() {
+		_ = pool.Close()
+	}()
+	for _, env := range []string{`development`, `notanenv`, `..`, `../environments/staging`, `Staging`} {
+		err := pool.Do(env, func(s api.Session) { t.Fatalf("environment %s was found", env) })
+		if _, ok := err.(*environments.NotFoundError); !ok {
+			t.Errorf("expected a NotFoundError for %s, got %v", env, err)
+		}
+	}
+}
+
+// TestPool_reap shows that sessions are closed when they have been idle for the idle timeout but never while they
+// are in use.
+func TestPool_reap(t *testing.T) {
+	pool := environments.NewPool(context.Background(), `testdata/environments`, nil, nil, 20*time.Millisecond)
+	defer func() {
+		_ = pool.Close()
+	}()
+	err := pool.Do(`production`, func(s api.Session) {
+		_ = pool.Do(`staging`, func(api.Session) {})
+		time.Sleep(100 * time.Millisecond)
+		if names := pool.Names(); !reflect.DeepEqual(names, []string{`production`}) {
+			t.Errorf("unexpected sessions %v", names)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if names := pool.Names(); len(names) != 0 {
+		t.Fatalf("unexpected sessions %v", names)
+	}
+}
+
+// TestPool_reapWhileProbed shows that idle sessions are closed while readiness probes run concurrently.
+func TestPool_reapWhileProbed(t *testing.T) {
+	pool := environments.NewPool(context.Background(), `testdata/environments`, nil, nil, 20*time.Millisecond)
+	defer func() {
+		_ = pool.Close()
+	}()
+	withRouter(t, `testdata/router.yaml`, &router.Config{Environments: pool}, func(h http.Handler) {
+		assertResponse(t, serve(h, `GET`, `/env/production/lookup/log_level`, ``, ``), http.StatusOK, `"warn"`)
+		done := make(chan struct{})
+		probed := make(chan struct{})
+		go func() {
+			defer close(probed)
+			for {
+				select {
+				case <-done:
+					return
+				default:
+					serve(h, `GET`, `/readyz`, ``, ``)
+				}
+			}
+		}()
+		deadline := time.Now().Add(time.Second)
+		for len(pool.Names()) > 0 && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		close(done)
+		<-probed
+		if names := pool.Names(); len(names) != 0 {
+			t.Fatalf("unexpected sessions %v", names)
+		}
+	})
+}
+
+// TestPool_handler shows how the router serves the environments of its pool under /env/<name>.
+func TestPool_handler(t *testing.T) {
+	pool := environments.NewPool(context.Background(), `testdata/environments`, nil, nil, 0)
+	defer func() {
+		_ = pool.Close()
+	}()
+	withRouter(t, `testdata/router.yaml`, &router.Config{Environments: pool}, func(h http.Handler) {
+		assertResponse(t, serve(h, `GET`, `/env/staging/lookup/log_level`, ``, ``), http.StatusOK, `"debug"`)
+		assertResponse(t, serve(h, `GET`, `/env/production/lookup/log_level`, ``, ``), http.StatusOK, `"warn"`)
+		assertResponse(t, serve(h, `POST`, `/env/production/lookup`, `{"keys": ["log_level"]}`, ``),
+			http.StatusOK, `{"log_level":{"value":"warn"}}`)
+		w := serve(h, `GET`, `/env/staging/explain/log_level`, ``, ``)
+		assertResponse(t, w, http.StatusOK, ``)
+		if !strings.HasPrefix(w.Body.String(), `{"found":true,"value":"debug"`) {
+			t.Fatalf("unexpected explanation %s", w.Body.String())
+		}
+
+		// The data of the server's own session is not visible in the environments and vice versa
+		assertResponse(t, serve(h, `GET`, `/env/staging/lookup/app`, ``, ``), http.StatusNotFound, ``)
+		assertResponse(t, serve(h, `GET`, `/lookup/log_level`, ``, ``), http.StatusNotFound, ``)
+
+		assertResponse(t, serve(h, `GET`, `/env/development/lookup/log_level`, ``, ``),
+			http.StatusNotFound, `environment 'development' not found`)
+		assertResponse(t, serve(h, `GET`, `/env/notanenv/lookup/log_level`, ``, ``),
+			http.StatusNotFound, `environment 'notanenv' not found`)
+		assertResponse(t, serve(h, `GET`, `/env/staging/other`, ``, ``), http.StatusNotFound, ``)
+		assertResponse(t, serve(h, `GET`, `/env/staging`, ``, ``), http.StatusNotFound, ``)
+	})
+
+	// Without a pool, no environments are served
+	withRouter(t, `testdata/router.yaml`, nil, func(h http.Handler) {
+		assertResponse(t, serve(h, `GET`, `/env/staging/lookup/log_level`, ``, ``), http.StatusNotFound, ``)
+	})
+}
+
+// TestPool_handlerReload shows that a POST to the /reload endpoint of the router also reloads the sessions of the
+// environments.
+func TestPool_handlerReload(t *testing.T) {
+	dir, err := ioutil.TempDir(``, `hiera-environments`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	envDir := filepath.Join(dir, `production`)
+	if err = os.MkdirAll(filepath.Join(envDir, `data`), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeReloadConfig(t, envDir, `a.yaml`)
+	writeReloadData(t, envDir, `a.yaml`, `a`)
+	writeReloadData(t, envDir, `b.yaml`, `b`)
+
+	pool := environments.NewPool(context.Background(), dir, nil, nil, 0)
+	defer func() {
+		_ = pool.Close()
+	}()
+	withRouter(t, `testdata/router.yaml`, &router.Config{Environments: pool}, func(h http.Handler) {
+		assertResponse(t, serve(h, `GET`, `/env/production/lookup/source`, ``, ``), http.StatusOK, `"a"`)
+		writeReloadConfig(t, envDir, `b.yaml`)
+		assertResponse(t, serve(h, `GET`, `/env/production/lookup/source`, ``, ``), http.StatusOK, `"a"`)
+		assertResponse(t, serve(h, `POST`, `/reload`, ``, ``), http.StatusOK, `reloaded`)
+		assertResponse(t, serve(h, `GET`, `/env/production/lookup/source`, ``, ``), http.StatusOK, `"b"`)
+	})
+}
+
+// TestPool_handlerReady shows that the /readyz endpoint of the router also checks the hiera.yaml of each environment
+// in the environments directory, including environments that have no session yet.
+func TestPool_handlerReady(t *testing.T) {
+	dir, err := ioutil.TempDir(``, `hiera-environments`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	for _, env := range []string{`production`, `staging`} {
+		if err = os.MkdirAll(filepath.Join(dir, env, `data`), 0755); err != nil {
+			t.Fatal(err)
+		}
+		writeReloadConfig(t, filepath.Join(dir, env), `a.yaml`)
+	}
+
+	pool := environments.NewPool(context.Background(), dir, nil, nil, 0)
+	defer func() {
+		_ = pool.Close()
+	}()
+	withRouter(t, `testdata/router.yaml`, &router.Config{Environments: pool}, func(h http.Handler) {
+		assertResponse(t, serve(h, `GET`, `/readyz`, ``, ``), http.StatusOK, `ok`)
+
+		err = ioutil.WriteFile(filepath.Join(dir, `staging`, `hiera.yaml`), []byte("version: 5\nhierarchy: [\n"), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := serve(h, `GET`, `/readyz`, ``, ``)
+		assertResponse(t, w, http.StatusServiceUnavailable, ``)
+		if !strings.HasPrefix(w.Body.String(), `environment 'staging': `) {
+			t.Fatalf("unexpected response %s", w.Body.String())
+		}
+	})
+}
+
+// TestPool_handlerReadyReap shows that the /readyz endpoint checks the environments without using their sessions, so
+// an environment that is only probed after its last lookup is still closed when it has been idle for a while.
+func TestPool_handlerReadyReap(t *testing.T) {
+	pool := environments.NewPool(context.Background(), `testdata/environments`, nil, nil, 20*time.Millisecond)
+	defer func() {
+		_ = pool.Close()
+	}()
+	withRouter(t, `testdata/router.yaml`, &router.Config{Environments: pool}, func(h http.Handler) {
+		assertResponse(t, serve(h, `GET`, `/env/staging/lookup/log_level`, ``, ``), http.StatusOK, `"debug"`)
+		for i := 0; i < 10; i++ {
+			assertResponse(t, serve(h, `GET`, `/readyz`, ``, ``), http.StatusOK, `ok`)
+			time.Sleep(10 * time.Millisecond)
+		}
+		if names := pool.Names(); len(names) != 0 {
+			t.Fatalf("unexpected sessions %v", names)
+		}
+	})
+}
@@ -1,8 +1,12 @@
 package examples_test
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"net/http"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/lyraproj/dgo/vf"
@@ -141,3 +145,84 @@ func TestExplain_withOnlyOptions(t *testing.T) {
 		}
 	})
 }
+
+// TestExplain_rendered shows how hiera.Explain returns both the text explanation and the explainer tree of a lookup.
+// The tree is rendered as nested JSON objects where each node has a "__type" entry.
+func TestExplain_rendered(t *testing.T) {
+	configOptions := map[string]string{api.HieraRoot: `testdata`}
+	hiera.DoWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) {
+		result := hiera.Explain(hs, &hiera.CommandOptions{}, []string{`hello`})
+		if !vf.True.Equals(result.Get(`found`)) || !vf.String(`yaml data says hello`).Equals(result.Get(`value`)) {
+			t.Fatalf("unexpected result %v", result)
+		}
+
+		expectedText := filepath.FromSlash(`Searching for "hello"
+  data_hash function 'yaml_data'
+    Path "testdata/data.yaml"
+      Original path: "data.yaml"
+      path not found
+  data_hash function 'yaml_data'
+    Path "testdata/data/common.yaml"
+      Original path: "common.yaml"
+      Found key: "hello" value: "yaml data says hello"`)
+		if expectedText != result.Get(`text`).String() {
+			t.Fatalf("expected explanation `%s` does not match actual `%s`", expectedText, result.Get(`text`))
+		}
+
+		out := bytes.Buffer{}
+		hiera.Render(hs, hiera.JSON, result.Get(`explanation`), &out)
+		var tree struct {
+			Branches []struct {
+				Type     string `json:"__type"`
+				Key      string `json:"key"`
+				Branches []map[string]interface{}
+			}
+		}
+		if err := json.Unmarshal(out.Bytes(), &tree); err != nil {
+			t.Fatal(err)
+		}
+		if len(tree.Branches) != 1 || tree.Branches[0].Type != `hiera.explainLookup` || tree.Branches[0].Key != `hello` ||
+			len(tree.Branches[0].Branches) != 2 {
+			t.Fatalf("unexpected explanation %s", out.String())
+		}
+
+		result = hiera.Explain(hs, &hiera.CommandOptions{}, []string{`nonexistent`})
+		if !vf.False.Equals(result.Get(`found`)) || result.Get(`value`) != nil {
+			t.Fatalf("unexpected result %v", result)
+		}
+	})
+}
+
+// TestExplain_handler shows how the router responds to requests to the /explain endpoint.
+func TestExplain_handler(t *testing.T) {
+	withRouter(t, `testdata/hiera.yaml`, nil, func(h http.Handler) {
+		w := serve(h, `GET`, `/explain/hello`, ``, ``)
+		assertResponse(t, w, http.StatusOK, ``)
+		if ct := w.Header().Get(`Content-Type`); ct != `application/json` {
+			t.Fatalf("unexpected content type %s", ct)
+		}
+		var result struct {
+			Found       bool                   `json:"found"`
+			Value       string                 `json:"value"`
+			Text        string                 `json:"text"`
+			Explanation map[string]interface{} `json:"explanation"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+			t.Fatal(err)
+		}
+		if !result.Found || result.Value != `yaml data says hello` || !strings.HasPrefix(result.Text, `Searching for "hello"`) ||
+			result.Explanation[`__type`] != `hiera.explainer` {
+			t.Fatalf("unexpected result %s", w.Body.String())
+		}
+
+		w = serve(h, `GET`, `/explain/nonexistent`, ``, ``)
+		assertResponse(t, w, http.StatusOK, ``)
+		if !strings.HasPrefix(w.Body.String(), `{"found":false,"text":"Searching for \"nonexistent\"`) {
+			t.Fatalf("unexpected result %s", w.Body.String())
+		}
+
+		assertResponse(t, serve(h, `GET`, `/explain/hello?explain_options=maybe`, ``, ``),
+			http.StatusBadRequest, `invalid value 'maybe' for parameter 'explain_options'`)
+		assertResponse(t, serve(h, `GET`, `/explain/hello?type=int`, ``, ``), http.StatusInternalServerError, ``)
+	})
+}
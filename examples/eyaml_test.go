@@ -0,0 +1,109 @@
+package examples_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lyraproj/dgo/dgo"
+	"github.com/lyraproj/dgo/vf"
+	"github.com/lyraproj/hiera/api"
+	"github.com/lyraproj/hiera/hiera"
+	"github.com/lyraproj/hiera/provider"
+)
+
+// TestEyamlLookupKey shows how the eyaml_lookup_key function decrypts hiera-eyaml ENC[PKCS7,...] blocks. Values
+// that contain encrypted blocks are returned as Sensitive values.
+func TestEyamlLookupKey(t *testing.T) {
+	configOptions := map[string]string{api.HieraConfig: `testdata/eyaml.yaml`}
+	hiera.DoWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) {
+		result := hiera.Lookup(hs.Invocation(nil, nil), `password`, nil, nil)
+		s, ok := result.(dgo.Sensitive)
+		if !ok || !vf.String(`s3cr3t`).Equals(s.Unwrap()) {
+			t.Fatalf("unexpected result %v", result)
+		}
+
+		result = hiera.Lookup(hs.Invocation(nil, nil), `database.password`, nil, nil)
+		s, ok = result.(dgo.Sensitive)
+		if !ok || !vf.String(`db_password`).Equals(s.Unwrap()) {
+			t.Fatalf("unexpected result %v", result)
+		}
+
+		result = hiera.Lookup(hs.Invocation(nil, nil), `tokens.0`, nil, nil)
+		s, ok = result.(dgo.Sensitive)
+		if !ok || !vf.String(`token api-token`).Equals(s.Unwrap()) {
+			t.Fatalf("unexpected result %v", result)
+		}
+
+		// values without encrypted blocks are returned as is
+		result = hiera.Lookup(hs.Invocation(nil, nil), `database.user`, nil, nil)
+		if !vf.String(`admin`).Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+	})
+}
+
+// TestEyamlLookupKey_cachedKeys shows that the keys are parsed once and then cached for the duration of the session.
+// The lookups continue to work after the key files have been removed.
+func TestEyamlLookupKey_cachedKeys(t *testing.T) {
+	dir, err := ioutil.TempDir(``, `hiera-eyaml`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	for _, f := range []string{`private_key.pkcs7.pem`, `public_key.pkcs7.pem`} {
+		bs, err := ioutil.ReadFile(filepath.Join(`testdata`, `keys`, f))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = ioutil.WriteFile(filepath.Join(dir, f), bs, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	datadir, err := filepath.Abs(filepath.Join(`testdata`, `data`, `eyaml`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := "version: 5\ndefaults:\n  datadir: " + datadir + "\nhierarchy:\n" +
+		"  - name: Secrets\n    lookup_key: eyaml_lookup_key\n    path: secrets.yaml\n    options:\n" +
+		"      pkcs7_private_key: " + filepath.Join(dir, `private_key.pkcs7.pem`) + "\n" +
+		"      pkcs7_public_key: " + filepath.Join(dir, `public_key.pkcs7.pem`) + "\n"
+	if err = ioutil.WriteFile(filepath.Join(dir, `hiera.yaml`), []byte(cfg), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	configOptions := map[string]string{api.HieraConfig: filepath.Join(dir, `hiera.yaml`)}
+	hiera.DoWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) {
+		result := hiera.Lookup(hs.Invocation(nil, nil), `password`, nil, nil)
+		if _, ok := result.(dgo.Sensitive); !ok {
+			t.Fatalf("unexpected result %v", result)
+		}
+
+		for _, f := range []string{`private_key.pkcs7.pem`, `public_key.pkcs7.pem`} {
+			if err := os.Remove(filepath.Join(dir, f)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		result = hiera.Lookup(hs.Invocation(nil, nil), `database.password`, nil, nil)
+		s, ok := result.(dgo.Sensitive)
+		if !ok || !vf.String(`db_password`).Equals(s.Unwrap()) {
+			t.Fatalf("unexpected result %v", result)
+		}
+	})
+}
+
+// TestEyamlLookupKey_missingKey shows that the eyaml_lookup_key function requires the key options.
+func TestEyamlLookupKey_missingKey(t *testing.T) {
+	configOptions := map[string]string{api.HieraConfig: `testdata/eyaml_nokeys.yaml`}
+	err := hiera.TryWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) error {
+		hiera.Lookup(hs.Invocation(nil, nil), `password`, nil, nil)
+		return nil
+	})
+	if err == nil || err.Error() != `missing required provider option 'pkcs7_public_key'` {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
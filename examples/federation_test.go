@@ -0,0 +1,68 @@
+package examples_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lyraproj/dgo/vf"
+	"github.com/lyraproj/hiera/api"
+	"github.com/lyraproj/hiera/hiera"
+	"github.com/lyraproj/hiera/hieraserver/router"
+	"github.com/lyraproj/hiera/provider"
+	"github.com/lyraproj/hiera/session"
+)
+
+// remoteHieraServer starts a server that serves the REST API of the hieraserver using the hierarchy of the given
+// configuration file. The hierarchy must not use plugins since the session of the server is never closed.
+func remoteHieraServer(configPath string) *httptest.Server {
+	hs := session.New(context.Background(), provider.ConfigLookupKey, map[string]string{api.HieraConfig: configPath}, nil)
+	return httptest.NewServer(router.New(hs, nil))
+}
+
+// TestHieraRest shows how the hiera_rest function delegates a level of the hierarchy to another hieraserver. The
+// merge strategy and the scope variables named in the "forward_scope" option are passed on to that server.
+func TestHieraRest(t *testing.T) {
+	ts := remoteHieraServer(`testdata/federation_remote.yaml`)
+	defer ts.Close()
+
+	scope := map[string]string{`remote`: ts.URL, `team`: `payments`}
+	configOptions := map[string]string{api.HieraConfig: `testdata/federation.yaml`}
+	hiera.DoWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) {
+		// The local level is found first
+		result := hiera.Lookup(hs.Invocation(scope, nil), `owner`, nil, nil)
+		if !vf.String(`local`).Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+
+		// The remote levels are merged by the remote server and then merged with the local level
+		result = hiera.Lookup(hs.Invocation(scope, nil), `tags`, nil, map[string]string{`merge`: `deep`})
+		if !vf.Map(`remote`, true, `team`, `payments`, `local`, true).Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+
+		// Not found on the remote server
+		result = hiera.Lookup(hs.Invocation(scope, nil), `missing`, nil, nil)
+		if result != nil {
+			t.Fatalf("unexpected result %v", result)
+		}
+	})
+}
+
+// TestHieraRest_forwardScopeTypes shows that the forwarded scope variables arrive at the remote server with the
+// type that they have in the local scope.
+func TestHieraRest_forwardScopeTypes(t *testing.T) {
+	ts := remoteHieraServer(`testdata/scope.yaml`)
+	defer ts.Close()
+
+	scope := map[string]interface{}{`remote`: ts.URL, `count`: 3, `enabled`: true, `id`: `42`}
+	configOptions := map[string]string{api.HieraConfig: `testdata/federation_scope.yaml`}
+	hiera.DoWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) {
+		for _, k := range []string{`count`, `enabled`, `id`} {
+			result := hiera.Lookup(hs.Invocation(scope, nil), k, nil, nil)
+			if !vf.Value(scope[k]).Equals(result) {
+				t.Fatalf("unexpected result %#v for variable %s", result, k)
+			}
+		}
+	})
+}
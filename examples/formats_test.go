@@ -0,0 +1,106 @@
+package examples_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/lyraproj/dgo/dgo"
+	"github.com/lyraproj/dgo/vf"
+	"github.com/lyraproj/hiera/api"
+	"github.com/lyraproj/hiera/hiera"
+	"github.com/lyraproj/hiera/provider"
+)
+
+// TestTomlData shows how the toml_data function reads TOML files. Numbers, booleans, and date-times retain their
+// TOML types and the keys of tables retain their order.
+func TestTomlData(t *testing.T) {
+	configOptions := map[string]string{api.HieraConfig: `testdata/formats.yaml`}
+	hiera.DoWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) {
+		expected := vf.Values(`common`, 8080, 0.75, true, vf.Time(mustParseTime(`2020-03-10T10:30:00Z`)), vf.Strings(`alpha`, `beta`))
+		result := vf.Values(
+			hiera.Lookup(hs.Invocation(nil, nil), `title`, nil, nil),
+			hiera.Lookup(hs.Invocation(nil, nil), `port`, nil, nil),
+			hiera.Lookup(hs.Invocation(nil, nil), `ratio`, nil, nil),
+			hiera.Lookup(hs.Invocation(nil, nil), `enabled`, nil, nil),
+			hiera.Lookup(hs.Invocation(nil, nil), `released`, nil, nil),
+			vf.Values(
+				hiera.Lookup(hs.Invocation(nil, nil), `servers.0.name`, nil, nil),
+				hiera.Lookup(hs.Invocation(nil, nil), `servers.1.name`, nil, nil)))
+		if !expected.Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+
+		owner, ok := hiera.Lookup(hs.Invocation(nil, nil), `owner`, nil, nil).(dgo.Map)
+		if !ok || !vf.Strings(`name`, `email`).Equals(owner.Keys()) {
+			t.Fatalf("unexpected result %v", owner)
+		}
+	})
+}
+
+// TestHclData shows how the hcl_data function reads the attributes of HCL files such as Terraform .tfvars files. The
+// keys of objects retain their order.
+func TestHclData(t *testing.T) {
+	configOptions := map[string]string{api.HieraConfig: `testdata/formats.yaml`}
+	hiera.DoWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) {
+		expected := vf.Values(`eu-west-1`, 3, vf.Strings(`a`, `b`))
+		result := vf.Values(
+			hiera.Lookup(hs.Invocation(nil, nil), `region`, nil, nil),
+			hiera.Lookup(hs.Invocation(nil, nil), `count`, nil, nil),
+			hiera.Lookup(hs.Invocation(nil, nil), `zones`, nil, nil))
+		if !expected.Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+
+		// database is found in the TOML, HCL, and INI files
+		db := hiera.Lookup(hs.Invocation(nil, nil), `database`, nil, map[string]string{`merge`: `deep`})
+		expectedDb := vf.Map(
+			`host`, `db.example.com`,
+			`ports`, vf.Values(5432, 5433),
+			`port`, 5432,
+			`timeout`, 2.5,
+			`ssl`, true,
+			`rotated`, vf.Time(mustParseTime(`2020-03-10T10:30:00Z`)))
+		if !expectedDb.Equals(db) {
+			t.Fatalf("unexpected result %v", db)
+		}
+
+		tags, ok := hiera.Lookup(hs.Invocation(nil, nil), `tags`, nil, nil).(dgo.Map)
+		if !ok || !vf.Strings(`team`, `app`, `location`).Equals(tags.Keys()) {
+			t.Fatalf("unexpected result %v", tags)
+		}
+		location, ok := tags.Get(`location`).(dgo.Map)
+		if !ok || !vf.Strings(`zone`, `region`).Equals(location.Keys()) {
+			t.Fatalf("unexpected result %v", location)
+		}
+	})
+}
+
+// TestIniData shows how the ini_data function reads INI files. Keys before the first section are entered at the
+// top level and sections become hashes. Values that look like numbers, booleans, or timestamps are converted, except
+// for numbers that would lose their formatting, such as the zip code "02134" and the version "1.10".
+func TestIniData(t *testing.T) {
+	configOptions := map[string]string{api.HieraConfig: `testdata/formats.yaml`}
+	hiera.DoWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) {
+		expected := vf.Values(`first`, 5, `second`, 3306, false, `02134`, `1.10`)
+		result := vf.Values(
+			hiera.Lookup(hs.Invocation(nil, nil), `name`, nil, nil),
+			hiera.Lookup(hs.Invocation(nil, nil), `retries`, nil, nil),
+			hiera.Lookup(hs.Invocation(nil, nil), `level`, nil, nil),
+			hiera.Lookup(hs.Invocation(nil, nil), `mysql.port`, nil, nil),
+			hiera.Lookup(hs.Invocation(nil, nil), `mysql.strict`, nil, nil),
+			hiera.Lookup(hs.Invocation(nil, nil), `zip`, nil, nil),
+			hiera.Lookup(hs.Invocation(nil, nil), `version`, nil, nil))
+		if !expected.Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+	})
+}
+
+func mustParseTime(s string) time.Time {
+	ts, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		panic(err)
+	}
+	return ts
+}
@@ -0,0 +1,244 @@
+package examples_test
+
+import (
+	"context"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lyraproj/dgo/util"
+	"github.com/lyraproj/dgo/vf"
+	"github.com/lyraproj/hiera/api"
+	"github.com/lyraproj/hiera/hiera"
+	"github.com/lyraproj/hiera/provider"
+)
+
+// TestHTTP shows how the http_lookup_key and http_data_hash functions obtain data from a server using the uri
+// locations of the hierarchy. Responses are decoded by content type and cached for the duration given by the
+// cache_ttl option or the max-age of their Cache-Control header.
+func TestHTTP(t *testing.T) {
+	var lock sync.Mutex
+	hits := map[string]int{}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		hits[r.URL.Path]++
+		lock.Unlock()
+		switch r.URL.Path {
+		case `/keys/token`:
+			if r.Header.Get(`Authorization`) != `Bearer secret-token` || r.Header.Get(`X-Tenant`) != `test` {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Header().Set(`Content-Type`, `text/plain`)
+			_, _ = w.Write([]byte(`the token`))
+		case `/data/common.yaml`:
+			w.Header().Set(`Cache-Control`, `max-age=60`)
+			w.Header().Set(`Content-Type`, `application/yaml`)
+			_, _ = w.Write([]byte("server:\n  port: 8080\n"))
+		case `/data/common.json`:
+			w.Header().Set(`Content-Type`, `application/json; charset=utf-8`)
+			_, _ = w.Write([]byte(`{"server": {"host": "example.com", "port": 80}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}
+	ts := httptest.NewTLSServer(http.HandlerFunc(handler))
+	defer ts.Close()
+
+	caFile := writeCA(t, ts)
+	defer func() { _ = os.RemoveAll(filepath.Dir(caFile)) }()
+
+	scope := map[string]string{`server`: ts.URL, `ca_file`: caFile}
+	configOptions := map[string]string{api.HieraConfig: `testdata/http.yaml`}
+	hiera.DoWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) {
+		result := hiera.Lookup(hs.Invocation(scope, nil), `token`, nil, nil)
+		if !vf.String(`the token`).Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+
+		result = hiera.Lookup(hs.Invocation(scope, nil), `server`, nil, map[string]string{`merge`: `deep`})
+		if !vf.Map(`host`, `example.com`, `port`, 8080).Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+
+		// Cached responses are reused
+		hiera.Lookup(hs.Invocation(scope, nil), `token`, nil, nil)
+		hiera.Lookup(hs.Invocation(scope, nil), `server`, nil, nil)
+		if hits[`/keys/token`] != 1 || hits[`/keys/server`] != 1 || hits[`/data/common.yaml`] != 1 {
+			t.Fatalf("unexpected hits %v", hits)
+		}
+	})
+}
+
+// TestHTTP_cache shows that responses are cached separately for each combination of uri and headers, that a cached
+// response expires after the duration given by the cache_ttl option, and that a response with a Cache-Control
+// no-store directive is never cached.
+func TestHTTP_cache(t *testing.T) {
+	var lock sync.Mutex
+	hits := map[string]int{}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		tenant := r.Header.Get(`X-Tenant`)
+		lock.Lock()
+		hits[tenant+r.URL.Path]++
+		lock.Unlock()
+		switch {
+		case r.URL.Path == `/keys/color` && tenant == `b`:
+			w.Header().Set(`Content-Type`, `text/plain`)
+			_, _ = w.Write([]byte(`blue`))
+		case r.URL.Path == `/keys/secret` && tenant == `a`:
+			w.Header().Set(`Cache-Control`, `no-store`)
+			w.Header().Set(`Content-Type`, `text/plain`)
+			_, _ = w.Write([]byte(`the secret`))
+		default:
+			http.NotFound(w, r)
+		}
+	}
+	ts := httptest.NewTLSServer(http.HandlerFunc(handler))
+	defer ts.Close()
+
+	caFile := writeCA(t, ts)
+	defer func() { _ = os.RemoveAll(filepath.Dir(caFile)) }()
+
+	scope := map[string]string{`server`: ts.URL, `ca_file`: caFile}
+	configOptions := map[string]string{api.HieraConfig: `testdata/http_cache.yaml`}
+	hiera.DoWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) {
+		lookup := func(key string) {
+			t.Helper()
+			result := hiera.Lookup(hs.Invocation(scope, nil), key, nil, nil)
+			expected := map[string]string{`color`: `blue`, `secret`: `the secret`}[key]
+			if !vf.String(expected).Equals(result) {
+				t.Fatalf("unexpected result %v", result)
+			}
+		}
+		assertHits := func(path string, expected int) {
+			t.Helper()
+			lock.Lock()
+			defer lock.Unlock()
+			if hits[path] != expected {
+				t.Fatalf("unexpected hits %v", hits)
+			}
+		}
+
+		// tenant a doesn't find the color, tenant b does
+		lookup(`color`)
+		lookup(`color`)
+		assertHits(`a/keys/color`, 1)
+		assertHits(`b/keys/color`, 1)
+
+		lookup(`secret`)
+		lookup(`secret`)
+		assertHits(`a/keys/secret`, 2)
+
+		time.Sleep(150 * time.Millisecond)
+		lookup(`color`)
+		assertHits(`a/keys/color`, 1)
+		assertHits(`b/keys/color`, 2)
+	})
+}
+
+// TestHTTP_reload shows that responses that have neither the cache_ttl option nor a max-age are cached until the
+// session is reloaded, and that a reload also rereads the CA file.
+func TestHTTP_reload(t *testing.T) {
+	var lock sync.Mutex
+	hits := 0
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != `/keys/key` {
+			http.NotFound(w, r)
+			return
+		}
+		lock.Lock()
+		hits++
+		lock.Unlock()
+		w.Header().Set(`Content-Type`, `text/plain`)
+		_, _ = w.Write([]byte(`the value`))
+	}))
+	defer ts.Close()
+
+	caFile := writeCA(t, ts)
+	defer func() { _ = os.RemoveAll(filepath.Dir(caFile)) }()
+
+	scope := map[string]string{`server`: ts.URL, `ca_file`: caFile}
+	configOptions := map[string]string{api.HieraConfig: `testdata/http_reload.yaml`}
+	hiera.DoWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) {
+		lookup := func() {
+			t.Helper()
+			result := hiera.Lookup(hs.Invocation(scope, nil), `key`, nil, nil)
+			if !vf.String(`the value`).Equals(result) {
+				t.Fatalf("unexpected result %v", result)
+			}
+		}
+		assertHits := func(expected int) {
+			t.Helper()
+			lock.Lock()
+			defer lock.Unlock()
+			if hits != expected {
+				t.Fatalf("unexpected hits %d", hits)
+			}
+		}
+
+		lookup()
+		lookup()
+		assertHits(1)
+
+		hs.Reload()
+		lookup()
+		assertHits(2)
+
+		if err := ioutil.WriteFile(caFile, []byte(`not a certificate`), 0600); err != nil {
+			t.Fatal(err)
+		}
+		hs.Reload()
+		err := util.Catch(func() { hiera.Lookup(hs.Invocation(scope, nil), `key`, nil, nil) })
+		if err == nil || !strings.HasSuffix(err.Error(), `does not contain any PEM encoded certificates`) {
+			t.Fatalf("unexpected error %v", err)
+		}
+	})
+}
+
+// TestHTTP_error shows that responses other than 200 OK and 404 Not Found result in an error.
+func TestHTTP_error(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == `/keys/token` {
+			http.Error(w, `access denied`, http.StatusForbidden)
+		} else {
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	caFile := writeCA(t, ts)
+	defer func() { _ = os.RemoveAll(filepath.Dir(caFile)) }()
+
+	scope := map[string]string{`server`: ts.URL, `ca_file`: caFile}
+	configOptions := map[string]string{api.HieraConfig: `testdata/http.yaml`}
+	err := hiera.TryWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) error {
+		hiera.Lookup(hs.Invocation(scope, nil), `token`, nil, nil)
+		return nil
+	})
+	if err == nil || err.Error() != `GET `+ts.URL+`/keys/token 403 Forbidden: access denied` {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+// writeCA writes the certificate of the given server to a file in a new temporary directory and returns the path
+// of that file
+func writeCA(t *testing.T, ts *httptest.Server) string {
+	t.Helper()
+	dir, err := ioutil.TempDir(``, `hiera-http`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caFile := filepath.Join(dir, `ca.pem`)
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: `CERTIFICATE`, Bytes: ts.Certificate().Raw})
+	if err = ioutil.WriteFile(caFile, pemBytes, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return caFile
+}
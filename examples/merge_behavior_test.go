@@ -0,0 +1,86 @@
+package examples_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/lyraproj/dgo/vf"
+	"github.com/lyraproj/hiera/api"
+	"github.com/lyraproj/hiera/explain"
+	"github.com/lyraproj/hiera/hiera"
+	"github.com/lyraproj/hiera/provider"
+)
+
+/*
+ The tests in this file use a hierarchy where the first level, "Secrets", declares "merge_behavior: skip_for_unique"
+ and the second level, "Node", declares "merge_behavior: stop".
+*/
+
+// TestMergeBehavior_skip shows that a level that declares skip_for_unique is excluded from unique merges but
+// not from lookups that use other strategies.
+func TestMergeBehavior_skip(t *testing.T) {
+	configOptions := map[string]string{api.HieraConfig: `testdata/merge_behavior.yaml`}
+	hiera.DoWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) {
+		result := hiera.Lookup(hs.Invocation(nil, nil), `users`, nil, map[string]string{`merge`: `unique`})
+		if !vf.Strings(`bob`, `carol`).Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+
+		result = hiera.Lookup(hs.Invocation(nil, nil), `users`, nil, nil)
+		if !vf.Strings(`root`).Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+	})
+}
+
+// TestMergeBehavior_stop shows that levels that follow a level that declares stop are not merged in once that
+// level yields a value.
+func TestMergeBehavior_stop(t *testing.T) {
+	configOptions := map[string]string{api.HieraConfig: `testdata/merge_behavior.yaml`}
+	hiera.DoWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) {
+		result := hiera.Lookup(hs.Invocation(nil, nil), `settings`, nil, map[string]string{`merge`: `hash`})
+		if !vf.Map(`a`, `node a`).Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+
+		// The "Node" level yields no value for "ntp" so the "Common" level is consulted
+		result = hiera.Lookup(hs.Invocation(nil, nil), `ntp`, nil, map[string]string{`merge`: `hash`})
+		if !vf.Map(`server`, `ntp.example.com`).Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+	})
+}
+
+// TestMergeBehavior_explain shows how the explainer reports levels that are not merged in.
+func TestMergeBehavior_explain(t *testing.T) {
+	configOptions := map[string]string{api.HieraConfig: `testdata/merge_behavior.yaml`}
+	hiera.DoWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) {
+		explainer := explain.NewExplainer(false, false)
+		hiera.Lookup(hs.Invocation(nil, explainer), `settings`, nil, map[string]string{`merge`: `unique`})
+
+		expectedExplanation := filepath.FromSlash(`Searching for "settings"
+  Using merge options from CLI option
+  Merge strategy "unique merge strategy"
+    data_hash function 'yaml_data'
+      Skipped due to merge_behavior "skip_for_unique"
+    data_hash function 'yaml_data'
+      Path "testdata/data/behavior/node.yaml"
+        Original path: "behavior/node.yaml"
+        Found key: "settings" value: {
+          "a": "node a"
+        }
+    data_hash function 'yaml_data'
+      Not merged due to merge_behavior "stop" in hierarchy level 'Node'
+    Merged result: {
+      {
+        "a": "node a"
+      }
+    }`)
+
+		actualExplanation := explainer.String()
+		if expectedExplanation != actualExplanation {
+			t.Fatalf("expected explanation `%s` does not match actual `%s`", expectedExplanation, actualExplanation)
+		}
+	})
+}
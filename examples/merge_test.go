@@ -2,10 +2,12 @@ package examples_test
 
 import (
 	"context"
+	"path/filepath"
 	"testing"
 
 	"github.com/lyraproj/dgo/vf"
 	"github.com/lyraproj/hiera/api"
+	"github.com/lyraproj/hiera/explain"
 	"github.com/lyraproj/hiera/hiera"
 	"github.com/lyraproj/hiera/merge"
 	"github.com/lyraproj/hiera/provider"
@@ -36,9 +38,8 @@ func TestMerge_default(t *testing.T) {
 }
 
 // TestMerge_deep shows how to pass a merge option in a lookup. The possible merge options are: First,
-// Unique, Hash, and Deep. Their behaviour should correspond to Puppet Hiera except for the current
-// limitation that Deep cannot be fine tuned with additional options. So no "knock_out_prefix" etc. just
-// yet.
+// Unique, Hash, and Deep. Their behaviour should correspond to Puppet Hiera. See TestMerge_deepOptions
+// for how Deep can be fine tuned with additional options.
 //
 // As with Puppet Hiera, merge options can also be specified as lookup_options in the data files.
 func TestMerge_deep(t *testing.T) {
@@ -83,3 +84,92 @@ func TestMerge_deep(t *testing.T) {
 		}
 	})
 }
+
+// TestMerge_deepOptions shows how the deep merge strategy can be fine tuned using the options knockout_prefix,
+// sort_merged_arrays, and merge_hash_arrays. The options are declared in the lookup_options of the data files.
+func TestMerge_deepOptions(t *testing.T) {
+	configOptions := map[string]string{api.HieraConfig: `testdata/deep_merge.yaml`}
+	hiera.DoWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) {
+		// The key "--bob" knocks out "bob" and the value "--" knocks out "carol"
+		result := hiera.Lookup(hs.Invocation(nil, nil), `users`, nil, nil)
+		expected := vf.Map(
+			`alice`, vf.Map(`uid`, 1001, `shell`, `bash`),
+			`dave`, vf.Map(`uid`, 1004))
+		if !expected.Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+
+		// The element "--vim" knocks out "vim" and the result is sorted
+		result = hiera.Lookup(hs.Invocation(nil, nil), `packages`, nil, nil)
+		if !vf.Strings(`curl`, `git`, `zsh`).Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+
+		// The hashes at the same index are merged
+		result = hiera.Lookup(hs.Invocation(nil, nil), `servers`, nil, nil)
+		expectedServers := vf.Values(
+			vf.Map(`name`, `one`, `port`, 8080, `host`, `a.example.com`),
+			vf.Map(`name`, `two`, `host`, `b.example.com`))
+		if !expectedServers.Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+
+		// Explicit merge options can be passed to the lookup
+		opts := vf.Map(`merge`, vf.Map(`strategy`, `deep`, `sort_merged_arrays`, true))
+		result = hiera.Lookup(hs.Invocation(nil, nil), `packages`, nil, opts)
+		if !vf.Strings(`--vim`, `curl`, `git`, `vim`, `zsh`).Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+
+		// The same options can be passed when calling merge.Deep explicitly
+		result, _ = merge.Deep(vf.Strings(`b`, `--c`), vf.Strings(`c`, `a`), vf.Map(`knockout_prefix`, `--`, `sort_merged_arrays`, true))
+		if !vf.Strings(`a`, `b`).Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+	})
+}
+
+// TestMerge_deepOptionsExplain shows that the explanation of a deep merge includes the options
+func TestMerge_deepOptionsExplain(t *testing.T) {
+	configOptions := map[string]string{api.HieraConfig: `testdata/deep_merge.yaml`}
+	hiera.DoWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) {
+		explainer := explain.NewExplainer(false, false)
+		result := hiera.Lookup(hs.Invocation(nil, explainer), `packages`, nil, nil)
+		if !vf.Strings(`curl`, `git`, `zsh`).Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+
+		expectedExplanation := filepath.FromSlash(`Searching for "packages"
+  Using merge options from "lookup_options" hash
+  Merge strategy "deep merge strategy"
+    Options: {
+      "knockout_prefix": "--",
+      "sort_merged_arrays": true
+    }
+    data_hash function 'yaml_data'
+      Path "testdata/data/deep1.yaml"
+        Original path: "deep1.yaml"
+        Found key: "packages" value: {
+          "zsh",
+          "--vim"
+        }
+    data_hash function 'yaml_data'
+      Path "testdata/data/deep2.yaml"
+        Original path: "deep2.yaml"
+        Found key: "packages" value: {
+          "vim",
+          "git",
+          "curl"
+        }
+    Merged result: {
+      "curl",
+      "git",
+      "zsh"
+    }`)
+
+		actualExplanation := explainer.String()
+		if expectedExplanation != actualExplanation {
+			t.Fatalf("expected explanation `%s` does not match actual `%s`", expectedExplanation, actualExplanation)
+		}
+	})
+}
@@ -0,0 +1,105 @@
+package examples_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lyraproj/dgo/vf"
+	"github.com/lyraproj/hiera/api"
+	"github.com/lyraproj/hiera/hiera"
+	"github.com/lyraproj/hiera/metrics"
+	"github.com/lyraproj/hiera/provider"
+)
+
+// TestMetrics shows how the metrics collected during lookups are served in the Prometheus text format.
+func TestMetrics(t *testing.T) {
+	metrics.Enable()
+	metrics.Reset()
+	defer metrics.Reset()
+
+	configOptions := vf.Map(api.HieraConfig, `testdata/batch.yaml`)
+	hiera.DoWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) {
+		out := bytes.Buffer{}
+		hiera.LookupAndRender(hs, &hiera.CommandOptions{}, []string{`port`}, &out)
+		hiera.LookupAndRender(hs, &hiera.CommandOptions{}, []string{`port`}, &out)
+		hiera.LookupAndRender(hs, &hiera.CommandOptions{}, []string{`missing.key`}, &out)
+		req, _ := hiera.ParseBatchRequest([]byte(`{"keys": ["port"], "type": "{port: map[string]int}"}`))
+		hiera.LookupBatch(hs, &hiera.CommandOptions{}, req)
+	})
+
+	w := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, `/metrics`, nil))
+	text := w.Body.String()
+	for _, expected := range []string{
+		"# TYPE hiera_lookups_total counter\n",
+		"hiera_lookups_total{result=\"not_found\",root=\"other\"} 1\n",
+		"hiera_lookups_total{result=\"error\",root=\"other\"} 1\n",
+		"hiera_lookups_total{result=\"found\",root=\"port\"} 2\n",
+		"# TYPE hiera_lookup_duration_seconds histogram\n",
+		"hiera_lookup_duration_seconds_bucket{root=\"port\",le=\"+Inf\"} 2\n",
+		"hiera_lookup_duration_seconds_count{root=\"port\"} 2\n",
+		"hiera_hierarchy_level_duration_seconds_count{level=\"Common\"}",
+		"hiera_cache_requests_total{cache=\"config\",result=\"miss\"} 1\n",
+		"hiera_cache_requests_total{cache=\"config\",result=\"hit\"} 3\n",
+		"hiera_cache_hit_ratio{cache=\"config\"} 0.75\n",
+	} {
+		if !strings.Contains(text, expected) {
+			t.Fatalf("expected metrics to contain %q, got:\n%s", expected, text)
+		}
+	}
+}
+
+// TestMetrics_maxRoots shows that the roots of lookups beyond metrics.MaxRoots are folded into the root label "other".
+func TestMetrics_maxRoots(t *testing.T) {
+	metrics.Enable()
+	metrics.Reset()
+	defer metrics.Reset()
+	saved := metrics.MaxRoots
+	metrics.MaxRoots = 1
+	defer func() { metrics.MaxRoots = saved }()
+
+	configOptions := vf.Map(api.HieraConfig, `testdata/batch.yaml`)
+	hiera.DoWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) {
+		for _, key := range []string{`port`, `packages`, `port`} {
+			hiera.LookupValue(hs, &hiera.CommandOptions{}, []string{key})
+		}
+	})
+
+	w := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, `/metrics`, nil))
+	text := w.Body.String()
+	for _, expected := range []string{
+		"hiera_lookups_total{result=\"found\",root=\"port\"} 2\n",
+		"hiera_lookups_total{result=\"found\",root=\"other\"} 1\n",
+	} {
+		if !strings.Contains(text, expected) {
+			t.Fatalf("expected metrics to contain %q, got:\n%s", expected, text)
+		}
+	}
+}
+
+// TestCheckConfig shows how hiera.CheckConfig detects a hiera.yaml that cannot be parsed or that uses a function
+// that cannot be resolved.
+func TestCheckConfig(t *testing.T) {
+	tests := map[string]string{
+		`testdata/batch.yaml`:            ``,
+		`testdata/check/broken.yaml`:     `yaml`,
+		`testdata/check/unresolved.yaml`: `no_such_function`,
+	}
+	for configPath, expected := range tests {
+		configOptions := vf.Map(api.HieraConfig, configPath)
+		hiera.DoWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) {
+			err := hiera.CheckConfig(hs)
+			switch {
+			case expected == `` && err != nil:
+				t.Fatalf("unexpected error for %s: %s", configPath, err)
+			case expected != `` && (err == nil || !strings.Contains(err.Error(), expected)):
+				t.Fatalf("expected an error containing '%s' for %s, got %v", expected, configPath, err)
+			}
+		})
+	}
+}
@@ -10,6 +10,8 @@ import (
 	"github.com/lyraproj/hiera/hiera"
 	"github.com/lyraproj/hiera/provider"
 	sdk "github.com/lyraproj/hierasdk/hiera"
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
 )
 
 // TestHelloWorld_globalAndModules uses the MuxLookupKey to inject two lookup_key functions. The ConfigLookupKey
@@ -42,6 +44,13 @@ func TestHelloWorld_globalAndModules(t *testing.T) {
 			t.Fatalf("unexpected result %v", result)
 		}
 
+		// A lookup of "one::rx_merge" is also found in both places. The module "one" declares a deep merge for
+		// all keys matching the regular expression "^one::rx_".
+		result = hiera.Lookup(hs.Invocation(nil, nil), `one::rx_merge`, nil, nil)
+		if result == nil || `{"a":"value of one::rx_merge a","b":"value of one::rx_merge b"}` != result.String() {
+			t.Fatalf("unexpected result %v", result)
+		}
+
 		// A lookup of "three::a" will not find a value because the "three" directory does not contain a hiera.yaml
 		result = hiera.Lookup(hs.Invocation(nil, nil), `three::a`, nil, nil)
 		if result != nil {
@@ -64,3 +73,25 @@ func TestHelloWorld_globalAndModules_nonExistentPath(t *testing.T) {
 		}
 	})
 }
+
+// TestModule_regexOutsideModule shows that a regular expression key in the lookup_options of a module that doesn't
+// start with "^<module name>::" is ignored with a warning.
+func TestModule_regexOutsideModule(t *testing.T) {
+	hook := logtest.NewGlobal()
+	defer hook.Reset()
+
+	configOptions := vf.Map(
+		provider.LookupKeyFunctions, []sdk.LookupKey{provider.ConfigLookupKey, provider.ModuleLookupKey},
+		api.HieraRoot, `testdata`,
+		provider.ModulePath, filepath.Join(`testdata`, `modules`))
+	hiera.DoWithParent(context.Background(), provider.MuxLookupKey, configOptions, func(hs api.Session) {
+		hiera.Lookup(hs.Invocation(nil, nil), `one::a`, nil, nil)
+	})
+	for _, e := range hook.AllEntries() {
+		if e.Level == logrus.WarnLevel &&
+			e.Message == `lookup_options key '^two::' of module 'one' is ignored since it doesn't start with '^one::'` {
+			return
+		}
+	}
+	t.Fatal(`expected a warning about the lookup_options key '^two::'`)
+}
@@ -0,0 +1,83 @@
+package examples_test
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lyraproj/hiera/api"
+	"github.com/lyraproj/hiera/explain"
+	"github.com/lyraproj/hiera/hiera"
+	"github.com/lyraproj/hiera/provider"
+)
+
+/*
+ The tests in this file use the api.HieraPositions option which makes the yaml_data and json_data providers retain
+ the line and column of every key in the files that they read.
+*/
+
+// TestPositions_explain shows how the explainer reports the position of each found key.
+func TestPositions_explain(t *testing.T) {
+	configOptions := map[string]interface{}{api.HieraConfig: `testdata/positions.yaml`, api.HieraPositions: true}
+	hiera.DoWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) {
+		explainer := explain.NewExplainer(false, false)
+		hiera.Lookup(hs.Invocation(nil, explainer), `settings`, nil, map[string]string{`merge`: `hash`})
+
+		expectedExplanation := filepath.FromSlash(`Searching for "settings"
+  Using merge options from CLI option
+  Merge strategy "hash merge strategy"
+    data_hash function 'yaml_data'
+      Path "testdata/data/positions/first.yaml"
+        Original path: "positions/first.yaml"
+        Found key: "settings" value: {
+          "port": 8080
+        } at testdata/data/positions/first.yaml:4:1
+    data_hash function 'json_data'
+      Path "testdata/data/positions/second.json"
+        Original path: "positions/second.json"
+        Found key: "settings" value: {
+          "host": "example.com"
+        } at testdata/data/positions/second.json:2:3
+    Merged result: {
+      "host": "example.com",
+      "port": 8080
+    }`)
+
+		actualExplanation := explainer.String()
+		if expectedExplanation != actualExplanation {
+			t.Fatalf("expected explanation `%s` does not match actual `%s`", expectedExplanation, actualExplanation)
+		}
+	})
+}
+
+// TestPositions_typeMismatch shows that a type assertion failure names the position of the offending key.
+func TestPositions_typeMismatch(t *testing.T) {
+	configOptions := map[string]interface{}{api.HieraConfig: `testdata/positions.yaml`, api.HieraPositions: true}
+	err := hiera.TryWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) error {
+		hiera.Lookup(hs.Invocation(nil, nil), `count`, nil, nil)
+		return nil
+	})
+	expected := filepath.FromSlash(`value of key 'count' found in hierarchy level 'JSON' at testdata/data/positions/second.json:5:3 ` +
+		`does not match the type declared in lookup_options: expected int, got string`)
+	if err == nil || err.Error() != expected {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+// TestPositions_interpolation shows that an interpolation error names the positions of the keys that were
+// interpolated.
+func TestPositions_interpolation(t *testing.T) {
+	configOptions := map[string]interface{}{api.HieraConfig: `testdata/positions.yaml`, api.HieraPositions: true}
+	err := hiera.TryWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) error {
+		hiera.Lookup(hs.Invocation(nil, nil), `broken`, nil, nil)
+		return nil
+	})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	expected := filepath.FromSlash(`testdata/data/positions/first.yaml:7:1: testdata/data/positions/first.yaml:9:1: `)
+	if !strings.HasPrefix(err.Error(), expected) {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
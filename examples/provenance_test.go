@@ -0,0 +1,133 @@
+package examples_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/lyraproj/dgo/dgo"
+	"github.com/lyraproj/dgo/vf"
+	"github.com/lyraproj/hiera/api"
+	"github.com/lyraproj/hiera/hiera"
+	"github.com/lyraproj/hiera/provider"
+)
+
+// TestLookupWithProvenance shows how the provenance of a merged value tells which hierarchy level and file
+// that each nested value was found in.
+func TestLookupWithProvenance(t *testing.T) {
+	configOptions := map[string]string{api.HieraConfig: `testdata/deep_merge.yaml`}
+	hiera.DoWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) {
+		result, provenance := hiera.LookupWithProvenance(hs.Invocation(nil, nil), `users.alice`, nil, nil)
+		if !vf.Map(`uid`, 1001, `shell`, `bash`).Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+		expected := vf.Map(
+			`uid`, vf.Map(
+				`level`, `First`,
+				`provider`, `data_hash function 'yaml_data'`,
+				`location`, filepath.FromSlash(`testdata/data/deep1.yaml`)),
+			`shell`, vf.Map(
+				`level`, `Second`,
+				`provider`, `data_hash function 'yaml_data'`,
+				`location`, filepath.FromSlash(`testdata/data/deep2.yaml`)))
+		if !expected.Equals(provenance) {
+			t.Fatalf("unexpected provenance %v", provenance)
+		}
+	})
+}
+
+// sourceInfo returns the provenance of a value that stems from the given hierarchy level and data file
+func sourceInfo(level, file string) dgo.Map {
+	return vf.Map(
+		`level`, level,
+		`provider`, `data_hash function 'yaml_data'`,
+		`location`, filepath.FromSlash(`testdata/data/`+file))
+}
+
+// TestLookupWithProvenance_arrays shows that the provenance of each element of a merged array is recorded during
+// the merge, so that equal elements from different levels are attributed to the level that supplied them and
+// elements that are knocked out don't affect the provenance of the remaining elements.
+func TestLookupWithProvenance_arrays(t *testing.T) {
+	configOptions := map[string]string{api.HieraConfig: `testdata/array_merge.yaml`}
+	hiera.DoWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) {
+		// "paths" are merged using the prepend policy which retains the duplicate /usr/bin
+		result, provenance := hiera.LookupWithProvenance(hs.Invocation(nil, nil), `paths`, nil, nil)
+		if !vf.Strings(`/opt/app/bin`, `/usr/bin`, `/usr/local/bin`, `/usr/bin`).Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+		first := sourceInfo(`First`, `array1.yaml`)
+		second := sourceInfo(`Second`, `array2.yaml`)
+		if !vf.Values(first, first, second, second).Equals(provenance) {
+			t.Fatalf("unexpected provenance %v", provenance)
+		}
+
+		// "search" is merged using the append policy
+		result, provenance = hiera.LookupWithProvenance(hs.Invocation(nil, nil), `search`, nil, nil)
+		if !vf.Strings(`two`, `three`, `one`, `two`).Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+		if !vf.Values(second, second, first, first).Equals(provenance) {
+			t.Fatalf("unexpected provenance %v", provenance)
+		}
+	})
+
+	configOptions = map[string]string{api.HieraConfig: `testdata/deep_merge.yaml`}
+	hiera.DoWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) {
+		// "packages" are sorted and the "--vim" in the first level knocks out the "vim" in the second level
+		result, provenance := hiera.LookupWithProvenance(hs.Invocation(nil, nil), `packages`, nil, nil)
+		if !vf.Strings(`curl`, `git`, `zsh`).Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+		first := sourceInfo(`First`, `deep1.yaml`)
+		second := sourceInfo(`Second`, `deep2.yaml`)
+		if !vf.Values(second, second, first).Equals(provenance) {
+			t.Fatalf("unexpected provenance %v", provenance)
+		}
+	})
+}
+
+// TestLookupWithProvenance_converted shows that a value that cannot be attributed to a single level, such as a value
+// converted using convert_to or a value merged by a registered merge strategy, has the provenance of all levels that
+// contributed to it.
+func TestLookupWithProvenance_converted(t *testing.T) {
+	configOptions := map[string]string{api.HieraConfig: `testdata/deep_merge.yaml`}
+	hiera.DoWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) {
+		// "credentials" are hash merged and then converted to a Sensitive. The sources of the entries of a hash are
+		// listed in key order.
+		result, provenance := hiera.LookupWithProvenance(hs.Invocation(nil, nil), `credentials`, nil, nil)
+		if _, ok := result.(dgo.Sensitive); !ok {
+			t.Fatalf("unexpected result %v", result)
+		}
+		expected := vf.Map(`sources`, vf.Values(sourceInfo(`Second`, `deep2.yaml`), sourceInfo(`First`, `deep1.yaml`)))
+		if !expected.Equals(provenance) {
+			t.Fatalf("unexpected provenance %v", provenance)
+		}
+	})
+
+	configOptions = map[string]string{api.HieraConfig: `testdata/custom_merge.yaml`}
+	hiera.DoWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) {
+		// "list" is merged using the registered "append_arrays" strategy
+		result, provenance := hiera.LookupWithProvenance(hs.Invocation(nil, nil), `list`, nil, nil)
+		if !vf.Strings(`a`, `b`, `b`, `c`).Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+		expected := vf.Map(`sources`, vf.Values(sourceInfo(`First`, `custom_merge1.yaml`), sourceInfo(`Second`, `custom_merge2.yaml`)))
+		if !expected.Equals(provenance) {
+			t.Fatalf("unexpected provenance %v", provenance)
+		}
+	})
+}
+
+// TestLookupWithProvenance_default shows that there is no provenance when the default value is used.
+func TestLookupWithProvenance_default(t *testing.T) {
+	configOptions := map[string]string{api.HieraConfig: `testdata/deep_merge.yaml`}
+	hiera.DoWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) {
+		result, provenance := hiera.LookupWithProvenance(hs.Invocation(nil, nil), `nonexistent`, vf.String(`default`), nil)
+		if result == nil || `default` != result.String() {
+			t.Fatalf("unexpected result %v", result)
+		}
+		if provenance != nil {
+			t.Fatalf("unexpected provenance %v", provenance)
+		}
+	})
+}
@@ -0,0 +1,106 @@
+package examples_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/lyraproj/dgo/vf"
+	"github.com/lyraproj/hiera/api"
+	"github.com/lyraproj/hiera/explain"
+	"github.com/lyraproj/hiera/hiera"
+	"github.com/lyraproj/hiera/provider"
+)
+
+// TestLookupOptions_regex shows how a lookup_options key that is declared as a regular expression using
+// "match: regex" applies to all keys that it matches.
+func TestLookupOptions_regex(t *testing.T) {
+	configOptions := map[string]string{api.HieraConfig: `testdata/regex_options.yaml`}
+	hiera.DoWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) {
+		// "profile::web::users" matches '^profile::(.*)::users$' which declares a hash merge
+		result := hiera.Lookup(hs.Invocation(nil, nil), `profile::web::users`, nil, nil)
+		expected := vf.Map(
+			`alice`, vf.Map(`shell`, `bash`),
+			`bob`, vf.Map(`shell`, `zsh`))
+		if !expected.Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+
+		// "profile::web::packages" matches '::packages$' which declares a unique merge
+		result = hiera.Lookup(hs.Invocation(nil, nil), `profile::web::packages`, nil, nil)
+		if !vf.Strings(`curl`, `git`, `nginx`).Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+	})
+}
+
+// TestLookupOptions_regexExactPrecedence shows that a lookup_options key that is equal to the looked up key has
+// precedence over a regular expression key that matches it.
+func TestLookupOptions_regexExactPrecedence(t *testing.T) {
+	configOptions := map[string]string{api.HieraConfig: `testdata/regex_options.yaml`}
+	hiera.DoWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) {
+		result := hiera.Lookup(hs.Invocation(nil, nil), `profile::db::users`, nil, nil)
+		if !vf.Map(`carol`, vf.Map(`uid`, 3)).Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+	})
+}
+
+// TestLookupOptions_regexExplain shows how the explainer reports the pattern that matched the key.
+func TestLookupOptions_regexExplain(t *testing.T) {
+	configOptions := map[string]string{api.HieraConfig: `testdata/regex_options.yaml`}
+	hiera.DoWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) {
+		explainer := explain.NewExplainer(false, false)
+		hiera.Lookup(hs.Invocation(nil, explainer), `profile::web::users`, nil, nil)
+
+		expectedExplanation := filepath.FromSlash(`Searching for "profile::web::users"
+  Using "lookup_options" matching pattern "^profile::(.*)::users$"
+  Using merge options from "lookup_options" hash
+  Merge strategy "hash merge strategy"
+    data_hash function 'yaml_data'
+      Path "testdata/data/regex1.yaml"
+        Original path: "regex1.yaml"
+        Found key: "profile::web::users" value: {
+          "alice": {
+            "shell": "bash"
+          }
+        }
+    data_hash function 'yaml_data'
+      Path "testdata/data/regex2.yaml"
+        Original path: "regex2.yaml"
+        Found key: "profile::web::users" value: {
+          "bob": {
+            "shell": "zsh"
+          }
+        }
+    Merged result: {
+      "bob": {
+        "shell": "zsh"
+      },
+      "alice": {
+        "shell": "bash"
+      }
+    }`)
+
+		actualExplanation := explainer.String()
+		if expectedExplanation != actualExplanation {
+			t.Fatalf("expected explanation `%s` does not match actual `%s`", expectedExplanation, actualExplanation)
+		}
+	})
+}
+
+// TestLookupOptions_caretWithoutMatch shows that a lookup_options key that starts with a caret is an exact key unless
+// it is declared as a regular expression using "match: regex".
+func TestLookupOptions_caretWithoutMatch(t *testing.T) {
+	configOptions := map[string]string{api.HieraConfig: `testdata/regex_options.yaml`}
+	hiera.DoWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) {
+		result := hiera.Lookup(hs.Invocation(nil, nil), `^caret`, nil, nil)
+		if !vf.Strings(`a`, `b`).Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+		result = hiera.Lookup(hs.Invocation(nil, nil), `caret`, nil, nil)
+		if !vf.Strings(`a`).Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+	})
+}
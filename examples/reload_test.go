@@ -0,0 +1,170 @@
+package examples_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lyraproj/dgo/vf"
+	"github.com/lyraproj/hiera/api"
+	"github.com/lyraproj/hiera/hiera"
+	"github.com/lyraproj/hiera/provider"
+)
+
+// writeReloadConfig writes a hiera.yaml to the given directory with a hierarchy that consists of the given data file
+func writeReloadConfig(t *testing.T, dir, dataFile string) {
+	t.Helper()
+	cfg := "version: 5\nhierarchy:\n  - name: Data\n    path: " + dataFile + "\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, `hiera.yaml`), []byte(cfg), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeReloadData(t *testing.T, dir, dataFile, value string) {
+	t.Helper()
+	if err := ioutil.WriteFile(filepath.Join(dir, `data`, dataFile), []byte(`source: `+value+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestReload shows how a reload of a session makes new invocations use a changed hiera.yaml while invocations that
+// were created before the reload continue to use the configuration that they started with.
+func TestReload(t *testing.T) {
+	dir, err := ioutil.TempDir(``, `hiera-reload`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	if err = os.Mkdir(filepath.Join(dir, `data`), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeReloadConfig(t, dir, `a.yaml`)
+	writeReloadData(t, dir, `a.yaml`, `a`)
+	writeReloadData(t, dir, `b.yaml`, `b`)
+
+	configOptions := vf.Map(api.HieraConfig, filepath.Join(dir, `hiera.yaml`))
+	hiera.DoWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) {
+		lookup := func(ic api.Invocation) string {
+			return hiera.Lookup(ic, `source`, nil, nil).String()
+		}
+		inFlight := hs.Invocation(nil, nil)
+		if v := lookup(inFlight); v != `a` {
+			t.Fatalf("unexpected result %s", v)
+		}
+
+		// The parsed hiera.yaml is cached so the change isn't visible until the session is reloaded
+		writeReloadConfig(t, dir, `b.yaml`)
+		if v := lookup(hs.Invocation(nil, nil)); v != `a` {
+			t.Fatalf("unexpected result %s", v)
+		}
+		hs.Reload()
+		if v := lookup(hs.Invocation(nil, nil)); v != `b` {
+			t.Fatalf("unexpected result %s", v)
+		}
+		if v := lookup(inFlight); v != `a` {
+			t.Fatalf("unexpected result from invocation created before reload %s", v)
+		}
+
+		// The watcher reloads the session when a file changes
+		w, err := hiera.WatchAndReload(hs, 10*time.Millisecond)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = w.Close()
+		}()
+		writeReloadConfig(t, dir, `a.yaml`)
+		deadline := time.Now().Add(5 * time.Second)
+		for lookup(hs.Invocation(nil, nil)) != `a` {
+			if time.Now().After(deadline) {
+				t.Fatal(`session was not reloaded after hiera.yaml changed`)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+}
+
+// newReloadDir creates a temporary directory with a hiera.yaml that uses the data file a.yaml where "source" is "a"
+func newReloadDir(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir(``, `hiera-reload`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Mkdir(filepath.Join(dir, `data`), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeReloadConfig(t, dir, `a.yaml`)
+	writeReloadData(t, dir, `a.yaml`, `a`)
+	return dir
+}
+
+// TestReload_handler shows how a POST to the /reload endpoint of the router makes changed data visible.
+func TestReload_handler(t *testing.T) {
+	dir := newReloadDir(t)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	withRouter(t, filepath.Join(dir, `hiera.yaml`), nil, func(h http.Handler) {
+		assertResponse(t, serve(h, `GET`, `/lookup/source`, ``, ``), http.StatusOK, `"a"`)
+
+		// The parsed hiera.yaml is cached so the change isn't visible until the server reloads
+		writeReloadData(t, dir, `b.yaml`, `b`)
+		writeReloadConfig(t, dir, `b.yaml`)
+		assertResponse(t, serve(h, `GET`, `/lookup/source`, ``, ``), http.StatusOK, `"a"`)
+
+		assertResponse(t, serve(h, `GET`, `/reload`, ``, ``), http.StatusMethodNotAllowed, ``)
+		assertResponse(t, serve(h, `POST`, `/reload`, ``, ``), http.StatusOK, `reloaded`)
+		assertResponse(t, serve(h, `GET`, `/lookup/source`, ``, ``), http.StatusOK, `"b"`)
+	})
+}
+
+// TestWatch shows how hiera.Watch calls its function after a data file has been rewritten, once the delay has passed.
+func TestWatch(t *testing.T) {
+	dir := newReloadDir(t)
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	configOptions := vf.Map(api.HieraConfig, filepath.Join(dir, `hiera.yaml`))
+	hiera.DoWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) {
+		lookup := func() string {
+			return hiera.Lookup(hs.Invocation(nil, nil), `source`, nil, nil).String()
+		}
+		if v := lookup(); v != `a` {
+			t.Fatalf("unexpected result %s", v)
+		}
+
+		const delay = 50 * time.Millisecond
+		reloaded := make(chan time.Time, 1)
+		w, err := hiera.Watch(delay, func() {
+			hs.Reload()
+			reloaded <- time.Now()
+		}, dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = w.Close()
+		}()
+
+		written := time.Now()
+		writeReloadData(t, dir, `a.yaml`, `changed`)
+		select {
+		case at := <-reloaded:
+			if at.Sub(written) < delay {
+				t.Fatalf("reloaded after %s, before the delay of %s", at.Sub(written), delay)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal(`no reload after the data file changed`)
+		}
+		if v := lookup(); v != `changed` {
+			t.Fatalf("unexpected result %s", v)
+		}
+	})
+}
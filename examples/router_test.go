@@ -0,0 +1,148 @@
+package examples_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lyraproj/hiera/api"
+	"github.com/lyraproj/hiera/hiera"
+	"github.com/lyraproj/hiera/hieraserver/acl"
+	"github.com/lyraproj/hiera/hieraserver/router"
+	"github.com/lyraproj/hiera/provider"
+)
+
+// withRouter calls the given function with a router that serves a session created from the given hiera.yaml
+func withRouter(t *testing.T, configPath string, cfg *router.Config, f func(http.Handler)) {
+	t.Helper()
+	configOptions := map[string]interface{}{api.HieraConfig: configPath}
+	hiera.DoWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) {
+		f(router.New(hs, cfg))
+	})
+}
+
+// serve sends a request with the given method, path, body, and bearer token to the given handler
+func serve(h http.Handler, method, path, body, token string) *httptest.ResponseRecorder {
+	var r *http.Request
+	if body == `` {
+		r = httptest.NewRequest(method, path, nil)
+	} else {
+		r = httptest.NewRequest(method, path, strings.NewReader(body))
+	}
+	if token != `` {
+		r.Header.Set(`Authorization`, `Bearer `+token)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func assertResponse(t *testing.T, w *httptest.ResponseRecorder, status int, body string) {
+	t.Helper()
+	if w.Code != status {
+		t.Fatalf("expected status %d, got %d: %s", status, w.Code, w.Body.String())
+	}
+	if body != `` && strings.TrimSpace(w.Body.String()) != body {
+		t.Fatalf("expected body %s, got %s", body, w.Body.String())
+	}
+}
+
+// TestRouter_acl shows how the access list of the router controls the lookup, explain, and batch endpoints, the
+// Sensitive values that are found, and the reload and metrics endpoints.
+func TestRouter_acl(t *testing.T) {
+	a, err := acl.Load(`testdata/acl.yaml`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	withRouter(t, `testdata/router.yaml`, &router.Config{ACL: a}, func(h http.Handler) {
+		tests := []struct {
+			name, method, path, body, token string
+			status                          int
+		}{
+			{`lookup without credentials`, `GET`, `/lookup/app`, ``, ``, http.StatusForbidden},
+			{`lookup of granted key`, `GET`, `/lookup/app.port`, ``, `reader`, http.StatusOK},
+			{`lookup of key that isn't granted`, `GET`, `/lookup/other`, ``, `reader`, http.StatusForbidden},
+			{`lookup of granted quoted key`, `GET`, `/lookup/"app".port`, ``, `reader`, http.StatusOK},
+			{`lookup of granted index`, `GET`, `/lookup/hosts.0`, ``, `lister`, http.StatusOK},
+			{`lookup of granted index with leading zero`, `GET`, `/lookup/hosts.00`, ``, `lister`, http.StatusOK},
+			{`lookup of index that isn't granted`, `GET`, `/lookup/hosts.1`, ``, `lister`, http.StatusForbidden},
+			{`lookup of parent of granted index`, `GET`, `/lookup/hosts`, ``, `lister`, http.StatusForbidden},
+			{`lookup of invalid key`, `GET`, `/lookup/app..port`, ``, `reader`, http.StatusForbidden},
+			{`lookup of sensitive value`, `GET`, `/lookup/db_password`, ``, `reader`, http.StatusForbidden},
+			{`lookup of granted sensitive value`, `GET`, `/lookup/db_password`, ``, `admin`, http.StatusOK},
+			{`explain of key that isn't granted`, `GET`, `/explain/other`, ``, `reader`, http.StatusForbidden},
+			{`explain of sensitive value`, `GET`, `/explain/db_password`, ``, `reader`, http.StatusForbidden},
+			{`explain of granted key`, `GET`, `/explain/app`, ``, `reader`, http.StatusOK},
+			{`explain of granted key with dot`, `GET`, `/explain/"dotted.key"`, ``, `lister`, http.StatusOK},
+			{`explain of parent segment of key with dot`, `GET`, `/explain/dotted`, ``, `lister`, http.StatusForbidden},
+			{`batch with key that isn't granted`, `POST`, `/lookup`, `{"keys": ["app", "other"]}`, `reader`, http.StatusForbidden},
+			{`batch with sensitive value`, `POST`, `/lookup`, `{"keys": ["app", "db_password"]}`, `reader`, http.StatusForbidden},
+			{`batch of granted keys`, `POST`, `/lookup`, `{"keys": ["app", "db_password"]}`, `admin`, http.StatusOK},
+			{`reload without grant`, `POST`, `/reload`, ``, `reader`, http.StatusForbidden},
+			{`reload with grant`, `POST`, `/reload`, ``, `admin`, http.StatusOK},
+			{`metrics without grant`, `GET`, `/metrics`, ``, `reader`, http.StatusForbidden},
+			{`metrics with grant`, `GET`, `/metrics`, ``, `admin`, http.StatusOK},
+			{`health needs no grant`, `GET`, `/healthz`, ``, ``, http.StatusOK},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				assertResponse(t, serve(h, tt.method, tt.path, tt.body, tt.token), tt.status, ``)
+			})
+		}
+	})
+}
+
+// TestRouter_noACL shows that all clients may access everything when the router has no access list.
+func TestRouter_noACL(t *testing.T) {
+	withRouter(t, `testdata/router.yaml`, nil, func(h http.Handler) {
+		assertResponse(t, serve(h, `GET`, `/lookup/app.port`, ``, ``), http.StatusOK, `8080`)
+		assertResponse(t, serve(h, `GET`, `/lookup/db_password`, ``, ``), http.StatusOK, ``)
+		assertResponse(t, serve(h, `POST`, `/reload`, ``, ``), http.StatusOK, `reloaded`)
+		assertResponse(t, serve(h, `GET`, `/lookup/missing`, ``, ``), http.StatusNotFound, `404 value not found`)
+	})
+}
+
+// TestRouter_explainProvenance shows that the provenance parameter cannot be used with the explain endpoint.
+func TestRouter_explainProvenance(t *testing.T) {
+	withRouter(t, `testdata/router.yaml`, nil, func(h http.Handler) {
+		assertResponse(t, serve(h, `GET`, `/explain/app?provenance=true`, ``, ``), http.StatusBadRequest,
+			`the provenance parameter cannot be used with explain`)
+		assertResponse(t, serve(h, `GET`, `/lookup/app?provenance=true`, ``, ``), http.StatusOK, ``)
+	})
+}
+
+// TestRouter_invalidMergeOptions shows that deep merge options given with another merge strategy result in a 400 Bad
+// Request.
+func TestRouter_invalidMergeOptions(t *testing.T) {
+	const msg = `the knockout prefix, sort merged arrays, and merge hash arrays options are only valid with merge 'deep'`
+	withRouter(t, `testdata/router.yaml`, nil, func(h http.Handler) {
+		assertResponse(t, serve(h, `GET`, `/lookup/app?knockout_prefix=--`, ``, ``), http.StatusBadRequest, msg)
+		assertResponse(t, serve(h, `GET`, `/lookup/app?merge=hash&sort_merged_arrays=true`, ``, ``), http.StatusBadRequest, msg)
+		assertResponse(t, serve(h, `GET`, `/explain/app?merge=first&merge_hash_arrays=true`, ``, ``), http.StatusBadRequest, msg)
+		assertResponse(t, serve(h, `POST`, `/lookup`, `{"keys": ["app"], "merge": "hash", "knockout_prefix": "--"}`, ``),
+			http.StatusBadRequest, msg)
+		assertResponse(t, serve(h, `GET`, `/lookup/app?merge=deep&knockout_prefix=--`, ``, ``), http.StatusOK, ``)
+	})
+}
+
+// TestRouter_invalidType shows that a type that cannot be parsed results in a 400 Bad Request.
+func TestRouter_invalidType(t *testing.T) {
+	const msg = `invalid type 'Integer': reference to unresolved type 'Integer': (column: 8)`
+	withRouter(t, `testdata/router.yaml`, nil, func(h http.Handler) {
+		assertResponse(t, serve(h, `GET`, `/lookup/app.port?type=Integer`, ``, ``), http.StatusBadRequest, msg)
+		assertResponse(t, serve(h, `GET`, `/explain/app.port?type=Integer`, ``, ``), http.StatusBadRequest, msg)
+		assertResponse(t, serve(h, `GET`, `/lookup/app.port?type=int`, ``, ``), http.StatusOK, `8080`)
+	})
+}
+
+// TestRouter_unknownMergeStrategy shows that a merge strategy that isn't registered results in a 400 Bad Request.
+func TestRouter_unknownMergeStrategy(t *testing.T) {
+	const msg = `unknown merge strategy 'bogus'`
+	withRouter(t, `testdata/router.yaml`, nil, func(h http.Handler) {
+		assertResponse(t, serve(h, `GET`, `/lookup/app?merge=bogus`, ``, ``), http.StatusBadRequest, msg)
+		assertResponse(t, serve(h, `GET`, `/explain/app?merge=bogus`, ``, ``), http.StatusBadRequest, msg)
+		assertResponse(t, serve(h, `POST`, `/lookup`, `{"keys": ["app"], "merge": "bogus"}`, ``), http.StatusBadRequest, msg)
+	})
+}
@@ -0,0 +1,105 @@
+package examples_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/lyraproj/dgo/vf"
+	"github.com/lyraproj/hiera/api"
+	"github.com/lyraproj/hiera/hiera"
+	"github.com/lyraproj/hiera/provider"
+)
+
+// TestSopsData shows how the sops_data function reads sops files and files that are encrypted in their entirety
+// using age. The age identities are read from the file appointed by the "age_key_file" option. All decrypted values
+// are Sensitive values.
+func TestSopsData(t *testing.T) {
+	configOptions := map[string]string{api.HieraConfig: `testdata/sops.yaml`}
+	hiera.DoWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) {
+		result := hiera.Lookup(hs.Invocation(nil, nil), `database`, nil, nil)
+		expected := vf.Map(
+			`user`, vf.Sensitive(`admin`),
+			`password`, vf.Sensitive(`secret`),
+			`port`, vf.Sensitive(5432),
+			`enabled`, vf.Sensitive(true))
+		if !expected.Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+
+		result = hiera.Lookup(hs.Invocation(nil, nil), `ports`, nil, nil)
+		if !vf.Values(vf.Sensitive(80), vf.Sensitive(443)).Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+
+		// the value of a key with the unencrypted suffix is not encrypted
+		result = hiera.Lookup(hs.Invocation(nil, nil), `comment_unencrypted`, nil, nil)
+		if !vf.String(`plain text`).Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+
+		// the sops metadata is not part of the data
+		result = hiera.Lookup(hs.Invocation(nil, nil), `sops`, nil, nil)
+		if result != nil {
+			t.Fatalf("unexpected result %v", result)
+		}
+
+		result = hiera.Lookup(hs.Invocation(nil, nil), `whole.token`, nil, nil)
+		if !vf.Sensitive(`age-token`).Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+
+		result = hiera.Lookup(hs.Invocation(nil, nil), `binary.token`, nil, nil)
+		if !vf.Sensitive(`binary-token`).Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+	})
+}
+
+// TestSopsData_missingKey shows the errors produced when the key needed to decrypt a file is missing.
+func TestSopsData_missingKey(t *testing.T) {
+	configOptions := map[string]string{api.HieraConfig: `testdata/sops_wrongkey.yaml`}
+	err := hiera.TryWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) error {
+		hiera.Lookup(hs.Invocation(nil, nil), `database`, nil, nil)
+		return nil
+	})
+	expected := filepath.FromSlash(
+		`none of the identities in age_key_file 'testdata/keys/other_age.txt' can decrypt testdata/data/sops/sops.yaml`)
+	if err == nil || err.Error() != expected {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	configOptions = map[string]string{api.HieraConfig: `testdata/sops_nokey.yaml`}
+	err = hiera.TryWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) error {
+		hiera.Lookup(hs.Invocation(nil, nil), `whole`, nil, nil)
+		return nil
+	})
+	if err == nil || err.Error() != `missing required provider option 'age_key_file'` {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+// TestSopsData_tampered shows that a sops file is rejected when its values don't match its message authentication
+// code, such as when encrypted values have been reordered, or when it contains a plaintext value with a key that
+// the sops metadata doesn't declare as unencrypted.
+func TestSopsData_tampered(t *testing.T) {
+	configOptions := map[string]string{api.HieraConfig: `testdata/sops_tampered.yaml`}
+	err := hiera.TryWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) error {
+		hiera.Lookup(hs.Invocation(nil, nil), `ports`, nil, nil)
+		return nil
+	})
+	expected := filepath.FromSlash(`the message authentication code of testdata/data/sops/tampered.yaml does not match its values`)
+	if err == nil || err.Error() != expected {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	configOptions = map[string]string{api.HieraConfig: `testdata/sops_plaintext.yaml`}
+	err = hiera.TryWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) error {
+		hiera.Lookup(hs.Invocation(nil, nil), `comment`, nil, nil)
+		return nil
+	})
+	expected = filepath.FromSlash(`value at 'comment' in sops file testdata/data/sops/plaintext.yaml is not encrypted`)
+	if err == nil || err.Error() != expected {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
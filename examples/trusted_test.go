@@ -0,0 +1,209 @@
+package examples_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lyraproj/dgo/vf"
+	"github.com/lyraproj/hiera/api"
+	"github.com/lyraproj/hiera/hiera"
+	"github.com/lyraproj/hiera/hieraserver/router"
+	"github.com/lyraproj/hiera/hieraserver/trusted"
+	"github.com/lyraproj/hiera/provider"
+)
+
+func trustedCertificate(t *testing.T) *x509.Certificate {
+	t.Helper()
+	role, err := asn1.MarshalWithParams(`webserver`, `utf8`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &x509.Certificate{
+		Subject:     pkix.Name{CommonName: `web01.example.com`},
+		DNSNames:    []string{`web01.example.com`, `www.example.com`},
+		IPAddresses: []net.IP{net.IPv4(10, 0, 0, 1)},
+		Extensions: []pkix.Extension{
+			{Id: asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 34380, 1, 1, 13}, Value: role},
+			{Id: asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 34380, 1, 1, 1}, Value: []byte{0x02, 0x01, 0x2a}}}}
+}
+
+// TestTrusted_fromCertificate shows the trusted hash that is built from a verified client certificate.
+func TestTrusted_fromCertificate(t *testing.T) {
+	var exts []trusted.Extension
+	for _, s := range []string{`pp_role=1.3.6.1.4.1.34380.1.1.13`, `1.3.6.1.4.1.34380.1.1.1`, `pp_zone=1.3.6.1.4.1.34380.1.1.99`} {
+		ext, err := trusted.ParseExtension(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		exts = append(exts, ext)
+	}
+	expected := vf.Map(
+		`authenticated`, `remote`,
+		`certname`, `web01.example.com`,
+		`hostname`, `web01`,
+		`domain`, `example.com`,
+		`dns_alt_names`, vf.Strings(`web01.example.com`, `www.example.com`),
+		`ip_alt_names`, vf.Strings(`10.0.0.1`),
+		`extensions`, vf.Map(`pp_role`, `webserver`, `1.3.6.1.4.1.34380.1.1.1`, `02012a`))
+	if tr := trusted.FromCertificate(trustedCertificate(t), exts); !expected.Equals(tr) {
+		t.Fatalf("unexpected trusted hash %v", tr)
+	}
+
+	if _, err := trusted.ParseExtension(`pp_role=1.3.x`); err == nil {
+		t.Fatal(`expected invalid OID to be rejected`)
+	}
+}
+
+// TestTrusted_scope shows how hierarchy paths use the trusted hash and that neither variables nor the scope of a batch
+// request can replace it.
+func TestTrusted_scope(t *testing.T) {
+	ext, err := trusted.ParseExtension(`pp_role=1.3.6.1.4.1.34380.1.1.13`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	configOptions := vf.Map(api.HieraConfig, `testdata/trusted.yaml`)
+	hiera.DoWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) {
+		opts := hiera.CommandOptions{
+			Variables: []string{`trusted={certname:"db01.example.com"}`},
+			Trusted:   trusted.FromCertificate(trustedCertificate(t), []trusted.Extension{ext})}
+		if v := hiera.LookupValue(hs, &opts, []string{`port`}); !vf.Value(8443).Equals(v) {
+			t.Fatalf("unexpected port %v", v)
+		}
+		if v := hiera.LookupValue(hs, &opts, []string{`packages`}); !vf.Strings(`nginx`).Equals(v) {
+			t.Fatalf("unexpected packages %v", v)
+		}
+
+		req, err := hiera.ParseBatchRequest([]byte(`{"keys": ["port"], "scope": {"trusted": {"certname": "db01.example.com"}}}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result := hiera.LookupBatch(hs, &opts, req); !vf.Map(`port`, vf.Map(`value`, 8443)).Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+	})
+}
+
+// newTLSServer starts a server for the given handler that requires a client certificate signed by a CA that is
+// created for the purpose, and returns it together with a client that presents the given certificate template signed
+// by that CA.
+func newTLSServer(t *testing.T, h http.Handler, clientTemplate *x509.Certificate) (*httptest.Server, *http.Client) {
+	t.Helper()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: `test CA`},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clientKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientTemplate.SerialNumber = big.NewInt(2)
+	clientTemplate.NotBefore = time.Now().Add(-time.Hour)
+	clientTemplate.NotAfter = time.Now().Add(time.Hour)
+	clientTemplate.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	clientTemplate.ExtraExtensions = clientTemplate.Extensions
+	clientDER, err := x509.CreateCertificate(rand.Reader, clientTemplate, ca, &clientKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cas := x509.NewCertPool()
+	cas.AddCert(ca)
+	srv := httptest.NewUnstartedServer(h)
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: cas}
+	srv.StartTLS()
+
+	client := srv.Client()
+	client.Transport.(*http.Transport).TLSClientConfig.Certificates = []tls.Certificate{
+		{Certificate: [][]byte{clientDER}, PrivateKey: clientKey}}
+	return srv, client
+}
+
+// TestTrusted_handler shows how the router adds the trusted hash built from the verified client certificate to the
+// scope of lookups and batch lookups, and that variables and the scope of a batch request cannot replace it.
+func TestTrusted_handler(t *testing.T) {
+	ext, err := trusted.ParseExtension(`pp_role=1.3.6.1.4.1.34380.1.1.13`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &router.Config{Extensions: []trusted.Extension{ext}}
+	withRouter(t, `testdata/trusted.yaml`, cfg, func(h http.Handler) {
+		srv, client := newTLSServer(t, h, trustedCertificate(t))
+		defer srv.Close()
+
+		get := func(path string) string {
+			t.Helper()
+			resp, err := client.Get(srv.URL + path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() {
+				_ = resp.Body.Close()
+			}()
+			bs, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("unexpected status %d: %s", resp.StatusCode, bs)
+			}
+			return strings.TrimSpace(string(bs))
+		}
+		if v := get(`/lookup/port`); v != `8443` {
+			t.Fatalf("unexpected port %s", v)
+		}
+		if v := get(`/lookup/packages`); v != `["nginx"]` {
+			t.Fatalf("unexpected packages %s", v)
+		}
+		if v := get(`/lookup/port?var=` + url.QueryEscape(`trusted={certname:"db01.example.com"}`)); v != `8443` {
+			t.Fatalf("variable replaced the trusted hash, got port %s", v)
+		}
+
+		resp, err := client.Post(srv.URL+`/lookup`, `application/json`,
+			strings.NewReader(`{"keys": ["port"], "scope": {"trusted": {"certname": "db01.example.com"}}}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+		bs, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.TrimSpace(string(bs)) != `{"port":{"value":8443}}` {
+			t.Fatalf("scope of batch request replaced the trusted hash, got %s", bs)
+		}
+	})
+}
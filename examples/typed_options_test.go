@@ -0,0 +1,72 @@
+package examples_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/lyraproj/dgo/vf"
+	"github.com/lyraproj/hiera/api"
+	"github.com/lyraproj/hiera/hiera"
+	"github.com/lyraproj/hiera/provider"
+)
+
+// TestLookupOptions_type shows how the "type" entry of the lookup_options declares the expected type of
+// the values found for a key. The type is parsed using the session dialect.
+func TestLookupOptions_type(t *testing.T) {
+	configOptions := map[string]string{api.HieraConfig: `testdata/typed_options.yaml`}
+	hiera.DoWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) {
+		result := hiera.Lookup(hs.Invocation(nil, nil), `port`, nil, nil)
+		if !vf.Value(8080).Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+	})
+}
+
+// TestLookupOptions_typeMerged shows that the declared type is checked against the merged value and not against
+// the values found in each hierarchy level. Here, each level supplies half of a struct with required keys.
+func TestLookupOptions_typeMerged(t *testing.T) {
+	configOptions := map[string]string{api.HieraConfig: `testdata/typed_options.yaml`}
+	hiera.DoWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) {
+		result := hiera.Lookup(hs.Invocation(nil, nil), `server`, nil, nil)
+		if !vf.Map(`host`, `alpha`, `port`, 80).Equals(result) {
+			t.Fatalf("unexpected result %v", result)
+		}
+	})
+}
+
+// TestLookupOptions_typeMismatch shows that a lookup fails when the merged value doesn't match the declared type.
+// The hierarchy level that supplied the value is named when the first merge strategy is used, and all levels that
+// supplied a merged value are named otherwise.
+func TestLookupOptions_typeMismatch(t *testing.T) {
+	configOptions := map[string]string{api.HieraConfig: `testdata/typed_options.yaml`}
+	err := hiera.TryWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) error {
+		hiera.Lookup(hs.Invocation(nil, nil), `hosts`, nil, nil)
+		return nil
+	})
+	expected := `merged value of key 'hosts' found in hierarchy levels 'First', 'Second' ` +
+		`does not match the type declared in lookup_options: expected []string, got []any`
+	if err == nil || err.Error() != expected {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	err = hiera.TryWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) error {
+		hiera.Lookup(hs.Invocation(nil, nil), `timeout`, nil, nil)
+		return nil
+	})
+	expected = filepath.FromSlash(`value of key 'timeout' found in hierarchy level 'Second', path "testdata/data/typed2.yaml" ` +
+		`does not match the type declared in lookup_options: expected int|float, got string`)
+	if err == nil || err.Error() != expected {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	err = hiera.TryWithParent(context.Background(), provider.ConfigLookupKey, configOptions, func(hs api.Session) error {
+		hiera.Lookup(hs.Invocation(nil, nil), `limits`, nil, nil)
+		return nil
+	})
+	expected = `merged value of key 'limits' found in hierarchy levels 'First', 'Second' ` +
+		`does not match the type declared in lookup_options: expected {"cpu":int,"memory":int}, got map[string]any`
+	if err == nil || err.Error() != expected {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
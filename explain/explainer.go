@@ -38,6 +38,7 @@ type explainNode interface {
 	branches() []explainNode
 	event() event
 	found(key interface{}, v dgo.Value)
+	foundAt(key interface{}, v dgo.Value, position string)
 	foundInDefaults(key interface{}, v dgo.Value)
 	foundInOverrides(key interface{}, v dgo.Value)
 	initMap() dgo.Map
@@ -47,11 +48,13 @@ type explainNode interface {
 	moduleNotFound()
 	notFound(k interface{})
 	parent() explainNode
+	position() string
 	result(result dgo.Value)
 	setBranches([]explainNode)
 	setEvent(event)
 	setKey(string)
 	setParent(explainNode)
+	setPosition(string)
 	setTexts([]string)
 	setValue(dgo.Value)
 	texts() []string
@@ -65,6 +68,7 @@ type explainTreeNode struct {
 	e  event
 	v  dgo.Value
 	k  string
+	ps string
 }
 
 var explainNodeRType = reflect.TypeOf((*explainNode)(nil)).Elem()
@@ -107,10 +111,13 @@ func initialize(en explainNode, ih dgo.Map) {
 	if v, ok := ih.Get(`key`).(dgo.String); ok {
 		en.setKey(v.GoString())
 	}
+	if v, ok := ih.Get(`position`).(dgo.String); ok {
+		en.setPosition(v.GoString())
+	}
 }
 
 func initMap(en explainNode) dgo.Map {
-	m := vf.MapWithCapacity(7)
+	m := vf.MapWithCapacity(8)
 	if bs := en.branches(); len(bs) > 0 {
 		m.Put(`branches`, vf.Array(bs))
 	}
@@ -126,6 +133,9 @@ func initMap(en explainNode) dgo.Map {
 	if ts := en.texts(); len(ts) > 0 {
 		m.Put(`texts`, vf.Array(ts))
 	}
+	if ps := en.position(); ps != `` {
+		m.Put(`position`, ps)
+	}
 	return m
 }
 
@@ -177,6 +187,9 @@ func (en *explainTreeNode) dumpOutcome(w dgo.Indenter) {
 			w.Append(` in defaults`)
 		} else if en.e == foundInOverrides {
 			w.Append(` in overrides`)
+		} else if en.ps != `` {
+			w.Append(` at `)
+			w.Append(en.ps)
 		}
 	}
 	en.dumpTexts(w)
@@ -209,6 +222,11 @@ func (en *explainTreeNode) found(key interface{}, v dgo.Value) {
 	en.e = found
 }
 
+func (en *explainTreeNode) foundAt(key interface{}, v dgo.Value, position string) {
+	en.found(key, v)
+	en.ps = position
+}
+
 func (en *explainTreeNode) foundInDefaults(key interface{}, v dgo.Value) {
 	en.k = keyToString(key)
 	en.v = v
@@ -247,6 +265,10 @@ func (en *explainTreeNode) parent() explainNode {
 	return en.p
 }
 
+func (en *explainTreeNode) position() string {
+	return en.ps
+}
+
 func (en *explainTreeNode) setBranches(bs []explainNode) {
 	en.bs = bs
 }
@@ -263,6 +285,10 @@ func (en *explainTreeNode) setParent(p explainNode) {
 	en.p = p
 }
 
+func (en *explainTreeNode) setPosition(ps string) {
+	en.ps = ps
+}
+
 func (en *explainTreeNode) setTexts(ts []string) {
 	en.ts = ts
 }
@@ -325,6 +351,44 @@ func (en *explainDataProvider) Equals(value interface{}) bool {
 	return en == value
 }
 
+type explainDefaultHierarchy struct {
+	explainTreeNode
+}
+
+var explainDefaultHierarchyType = tf.NewNamed(
+	`hiera.explainDefaultHierarchy`,
+	func(value dgo.Value) dgo.Value { return createFunc(value, &explainDefaultHierarchy{}) },
+	extractFunc,
+	reflect.TypeOf(&explainDefaultHierarchy{}),
+	explainNodeRType,
+	nil)
+
+func (en *explainDefaultHierarchy) Type() dgo.Type {
+	return tf.ExactNamed(explainDefaultHierarchyType, en)
+}
+
+func (en *explainDefaultHierarchy) initialize(ih dgo.Map) {
+	initialize(en, ih)
+}
+
+func (en *explainDefaultHierarchy) initMap() dgo.Map {
+	return initMap(en)
+}
+
+func (en *explainDefaultHierarchy) Equals(value interface{}) bool {
+	return en == value
+}
+
+func (en *explainDefaultHierarchy) AppendTo(w dgo.Indenter) {
+	w.NewLine()
+	w.Append(`Searching default_hierarchy`)
+	en.dumpBranches(w.Indent())
+}
+
+func (en *explainDefaultHierarchy) String() string {
+	return util.ToIndentedString(en)
+}
+
 type explainInterpolate struct {
 	explainTreeNode
 	expression string
@@ -638,6 +702,51 @@ func (en *explainMerge) String() string {
 	return util.ToIndentedString(en)
 }
 
+type explainLookupOptionsPattern struct {
+	explainTreeNode
+	pattern string
+}
+
+var explainLookupOptionsPatternType = tf.NewNamed(
+	`hiera.explainLookupOptionsPattern`,
+	func(value dgo.Value) dgo.Value { return createFunc(value, &explainLookupOptionsPattern{}) },
+	extractFunc,
+	reflect.TypeOf(&explainLookupOptionsPattern{}),
+	explainNodeRType,
+	nil)
+
+func (en *explainLookupOptionsPattern) Type() dgo.Type {
+	return tf.ExactNamed(explainLookupOptionsPatternType, en)
+}
+
+func (en *explainLookupOptionsPattern) initialize(ih dgo.Map) {
+	initialize(en, ih)
+	if ms, ok := ih.Get(`pattern`).(dgo.String); ok {
+		en.pattern = ms.GoString()
+	}
+}
+
+func (en *explainLookupOptionsPattern) initMap() dgo.Map {
+	m := initMap(en)
+	m.Put(`pattern`, en.pattern)
+	return m
+}
+
+func (en *explainLookupOptionsPattern) Equals(value interface{}) bool {
+	return en == value
+}
+
+func (en *explainLookupOptionsPattern) AppendTo(w dgo.Indenter) {
+	w.NewLine()
+	w.Append(`Using "lookup_options" matching pattern "`)
+	w.Append(en.pattern)
+	w.Append(`"`)
+}
+
+func (en *explainLookupOptionsPattern) String() string {
+	return util.ToIndentedString(en)
+}
+
 type explainMergeSource struct {
 	explainTreeNode
 	mergeSource string
@@ -851,6 +960,10 @@ func (ex *explainer) AcceptFound(key interface{}, value dgo.Value) {
 	ex.current.found(key, value)
 }
 
+func (ex *explainer) AcceptFoundAt(key interface{}, value dgo.Value, position string) {
+	ex.current.foundAt(key, value, position)
+}
+
 func (ex *explainer) AcceptFoundInDefaults(key string, value dgo.Value) {
 	ex.current.foundInDefaults(key, value)
 }
@@ -863,6 +976,12 @@ func (ex *explainer) AcceptLocationNotFound() {
 	ex.current.locationNotFound()
 }
 
+func (ex *explainer) AcceptLookupOptionsPattern(pattern string) {
+	en := &explainLookupOptionsPattern{pattern: pattern}
+	en.p = ex.current
+	ex.current.appendBranch(en)
+}
+
 func (ex *explainer) AcceptMergeSource(mergeSource string) {
 	en := &explainMergeSource{mergeSource: mergeSource}
 	en.p = ex.current
@@ -895,6 +1014,10 @@ func (ex *explainer) PushDataProvider(pvd api.DataProvider) {
 	ex.push(&explainDataProvider{providerName: pvd.FullName()})
 }
 
+func (ex *explainer) PushDefaultHierarchy() {
+	ex.push(&explainDefaultHierarchy{})
+}
+
 func (ex *explainer) PushInterpolation(expr string) {
 	ex.push(&explainInterpolate{expression: expr})
 }
@@ -1,16 +1,29 @@
 module github.com/lyraproj/hiera
 
 require (
+	filippo.io/age v1.0.0
+	github.com/BurntSushi/toml v0.3.1
 	github.com/bmatcuk/doublestar v1.2.2
+	github.com/cespare/xxhash/v2 v2.1.2 // indirect
+	github.com/fsnotify/fsnotify v1.4.9
+	github.com/golang/protobuf v1.5.2 // indirect
+	github.com/hashicorp/hcl/v2 v2.8.2
 	github.com/lyraproj/dgo v0.4.4
 	github.com/lyraproj/dgoyaml v0.4.4
 	github.com/lyraproj/hierasdk v0.4.4
 	github.com/niemeyer/pretty v0.0.0-20200227124842-a10e7caefd8e // indirect
-	github.com/sirupsen/logrus v1.4.2
+	github.com/prometheus/client_golang v1.10.0
+	github.com/prometheus/procfs v0.7.3 // indirect
+	github.com/sirupsen/logrus v1.8.1
 	github.com/spf13/cobra v0.0.6
 	github.com/stretchr/testify v1.5.1
-	golang.org/x/sys v0.0.0-20200302150141-5c8b2ff67527
+	github.com/zclconf/go-cty v1.2.0
+	go.mozilla.org/pkcs7 v0.0.0-20200128120323-432b2356ecb1
+	golang.org/x/sys v0.0.0-20210903071746-97244b99971b
+	google.golang.org/protobuf v1.27.1 // indirect
 	gopkg.in/check.v1 v1.0.0-20200227125254-8fa46927fb4f // indirect
+	gopkg.in/ini.v1 v1.66.2
+	gopkg.in/yaml.v2 v2.4.0 // indirect
 	gopkg.in/yaml.v3 v3.0.0-20200121175148-a6ecf24a6d71
 )
 
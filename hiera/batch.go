@@ -0,0 +1,187 @@
+package hiera
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lyraproj/dgo/dgo"
+	"github.com/lyraproj/dgo/streamer"
+	"github.com/lyraproj/dgo/util"
+	"github.com/lyraproj/dgo/vf"
+	"github.com/lyraproj/hiera/api"
+	"github.com/lyraproj/hiera/metrics"
+)
+
+// A BatchRequest is a request to look up several keys using one scope. It is the JSON body of a POST to the /lookup
+// endpoint of the REST server.
+type BatchRequest struct {
+	// Keys are the keys to look up
+	Keys []string
+
+	// Scope is added to the scope of the lookup. Unlike variables, its values can be nested maps.
+	Scope dgo.Map
+
+	// Defaults is a map of values to use for keys that are not found
+	Defaults dgo.Map
+
+	// Type is a struct map type string such as "{a: string, b: []int}" used for assertion of each found value
+	Type string
+
+	// Merge is the name of a merge strategy
+	Merge string
+
+	// KnockoutPrefix is the knockout_prefix to use with the deep merge strategy
+	KnockoutPrefix string
+
+	// SortMergedArrays should be set to true to sort arrays merged by the deep merge strategy
+	SortMergedArrays bool
+
+	// MergeHashArrays should be set to true to let the deep merge strategy merge arrays of hashes
+	MergeHashArrays bool
+}
+
+// ParseBatchRequest parses the given JSON into a BatchRequest. An error is returned if the JSON is invalid or if an
+// entry has an unexpected type.
+func ParseBatchRequest(bs []byte) (req *BatchRequest, err error) {
+	err = util.Catch(func() {
+		m, ok := streamer.UnmarshalJSON(bs, nil).(dgo.Map)
+		if !ok {
+			panic(errors.New(`batch request is not a JSON object`))
+		}
+		req = &BatchRequest{}
+		m.EachEntry(func(e dgo.MapEntry) { req.set(e.Key().String(), e.Value()) })
+		if len(req.Keys) == 0 {
+			panic(errors.New(`batch request has no keys`))
+		}
+		if _, err := mergeOptions(req.commandOptions()); err != nil {
+			panic(err)
+		}
+	})
+	if err != nil {
+		req = nil
+	}
+	return
+}
+
+func (req *BatchRequest) set(name string, v dgo.Value) {
+	ok := true
+	switch name {
+	case `keys`:
+		var ka dgo.Array
+		if ka, ok = v.(dgo.Array); ok {
+			req.Keys = make([]string, ka.Len())
+			ka.EachWithIndex(func(k dgo.Value, i int) {
+				if ks, isString := k.(dgo.String); isString {
+					req.Keys[i] = ks.GoString()
+				} else {
+					ok = false
+				}
+			})
+		}
+	case `scope`:
+		req.Scope, ok = v.(dgo.Map)
+	case `defaults`:
+		req.Defaults, ok = v.(dgo.Map)
+	case `type`:
+		req.Type, ok = stringEntry(v)
+	case `merge`:
+		req.Merge, ok = stringEntry(v)
+	case `knockout_prefix`:
+		req.KnockoutPrefix, ok = stringEntry(v)
+	case `sort_merged_arrays`:
+		req.SortMergedArrays, ok = boolEntry(v)
+	case `merge_hash_arrays`:
+		req.MergeHashArrays, ok = boolEntry(v)
+	default:
+		panic(fmt.Errorf(`unknown batch request entry '%s'`, name))
+	}
+	if !ok {
+		panic(fmt.Errorf(`invalid value for batch request entry '%s': %s`, name, v))
+	}
+}
+
+// commandOptions returns the merge options of the request as CommandOptions
+func (req *BatchRequest) commandOptions() *CommandOptions {
+	return &CommandOptions{
+		Merge:            req.Merge,
+		KnockoutPrefix:   req.KnockoutPrefix,
+		SortMergedArrays: req.SortMergedArrays,
+		MergeHashArrays:  req.MergeHashArrays}
+}
+
+func stringEntry(v dgo.Value) (string, bool) {
+	s, ok := v.(dgo.String)
+	if !ok {
+		return ``, false
+	}
+	return s.GoString(), true
+}
+
+func boolEntry(v dgo.Value) (bool, bool) {
+	b, ok := v.(dgo.Boolean)
+	if !ok {
+		return false, false
+	}
+	return b.GoBool(), true
+}
+
+// LookupBatch looks up all keys of the given request using LookupAll and returns a map with one entry per key. The
+// entry is a map with a "value" entry when the key is found, a "not_found" entry with the value true when it isn't,
+// and an "error" entry with the error message when the lookup of that key fails. A failing key does not prevent the
+// other keys from being looked up.
+//
+// The scope of the lookup is created from the variables of the given options with the scope of the request added on
+// top. The trusted hash of the options is never replaced by the scope of the request. The options are typically the
+// ones given when the REST server was started.
+func LookupBatch(c api.Session, opts *CommandOptions, req *BatchRequest) dgo.Map {
+	var stp dgo.StructMapType
+	if req.Type != `` {
+		var ok bool
+		if stp, ok = parseType(c, req.Type).(dgo.StructMapType); !ok {
+			panic(fmt.Errorf("type must be a map"))
+		}
+	}
+
+	var options dgo.Map
+	mo, err := mergeOptions(req.commandOptions())
+	if err != nil {
+		panic(err)
+	}
+	if mo != nil {
+		options = vf.Map(`merge`, mo)
+	}
+
+	scope := createScope(c, opts)
+	if req.Scope != nil {
+		scope.PutAll(req.Scope)
+		if opts.Trusted != nil {
+			scope.Put(`trusted`, opts.Trusted)
+		}
+	}
+
+	result := vf.MapWithCapacity(len(req.Keys))
+	for _, key := range req.Keys {
+		start := time.Now()
+		var found dgo.Map
+		err := util.Catch(func() {
+			found = LookupAll(c.Invocation(scope, nil), []string{key}, stp, nil, req.Defaults, options).(dgo.Map)
+		})
+		var outcome string
+		switch {
+		case err != nil:
+			result.Put(key, vf.Map(`error`, err.Error()))
+			outcome = metrics.Error
+		case found.Len() == 0:
+			result.Put(key, vf.Map(`not_found`, true))
+			outcome = metrics.NotFound
+		default:
+			result.Put(key, vf.Map(`value`, found.Get(key)))
+			outcome = metrics.Found
+		}
+		if metrics.Enabled() {
+			metrics.ObserveLookup(keyRoot(key), outcome, start)
+		}
+	}
+	return result
+}
@@ -0,0 +1,37 @@
+package hiera
+
+import (
+	"fmt"
+
+	"github.com/lyraproj/dgo/util"
+	"github.com/lyraproj/hiera/api"
+	"github.com/lyraproj/hiera/config"
+	"github.com/lyraproj/hiera/internal"
+	"github.com/lyraproj/hiera/session"
+)
+
+// CheckConfig parses the hiera.yaml of the given session and loads the functions of all levels in its hierarchy and
+// default_hierarchy. An error is returned when the file cannot be parsed, when a function cannot be found, or when a
+// plugin fails to start. The file is always read from disk so that changes made after the session was created are
+// detected.
+func CheckConfig(c api.Session) error {
+	return CheckConfigFile(c, c.SessionOptions().Get(api.HieraConfig).String())
+}
+
+// CheckConfigFile is like CheckConfig but checks the hiera.yaml at the given path instead of the one of the session.
+// The functions are loaded by the given session.
+func CheckConfigFile(c api.Session, path string) error {
+	return util.Catch(func() {
+		ic := c.Invocation(nil, nil)
+		cfg := config.New(path)
+		defaults := cfg.Defaults().Resolve(ic, nil)
+		for _, hs := range [][]api.Entry{cfg.Hierarchy(), cfg.DefaultHierarchy()} {
+			for _, he := range hs {
+				dp := session.CreateProvider(he.Resolve(ic, defaults))
+				if !internal.FunctionResolved(ic, dp) {
+					panic(fmt.Errorf(`%s of hierarchy level '%s' cannot be resolved`, dp.FullName(), he.Name()))
+				}
+			}
+		}
+	})
+}
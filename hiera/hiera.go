@@ -3,21 +3,24 @@ package hiera
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/lyraproj/dgo/dgo"
-	"github.com/lyraproj/dgo/streamer"
 	"github.com/lyraproj/dgo/typ"
 	"github.com/lyraproj/dgo/util"
 	"github.com/lyraproj/dgo/vf"
 	"github.com/lyraproj/dgoyaml/yaml"
 	"github.com/lyraproj/hiera/api"
 	"github.com/lyraproj/hiera/explain"
+	"github.com/lyraproj/hiera/merge"
+	"github.com/lyraproj/hiera/metrics"
 	"github.com/lyraproj/hiera/session"
 	"github.com/lyraproj/hierasdk/hiera"
 )
@@ -31,6 +34,15 @@ type CommandOptions struct {
 	// Merge is the name of a merge strategy
 	Merge string
 
+	// KnockoutPrefix is the knockout_prefix to use with the deep merge strategy
+	KnockoutPrefix string
+
+	// SortMergedArrays should be set to true to sort arrays merged by the deep merge strategy
+	SortMergedArrays bool
+
+	// MergeHashArrays should be set to true to let the deep merge strategy merge arrays of hashes
+	MergeHashArrays bool
+
 	// Default is a pointer to the string representation of a default value or nil if no default value exists
 	Default *string
 
@@ -44,6 +56,10 @@ type CommandOptions struct {
 	// Variables are an optional paths to a files containing extra variables to add to the lookup scope
 	Variables []string
 
+	// Trusted is added to the lookup scope as the variable "trusted" after all other variables so that neither
+	// variables nor facts can replace it. The REST server builds it from the verified client certificate.
+	Trusted dgo.Map
+
 	// RenderAs is the name of the desired rendering
 	RenderAs string
 
@@ -53,6 +69,10 @@ type CommandOptions struct {
 	// ExplainOptions should be set to true to explain how lookup options were found for the lookup
 	ExplainOptions bool
 
+	// Provenance should be set to true to render the found value together with a tree that shows which
+	// hierarchy level and location that each part of the value was found in
+	Provenance bool
+
 	LookupAll bool
 }
 
@@ -211,14 +231,96 @@ var needParsePrefix = []string{`{`, `[`, `"`, `'`}
 // LookupAndRender performs a lookup using the given command options and arguments and renders the result on the given
 // io.Writer in accordance with the `RenderAs` option.
 func LookupAndRender(c api.Session, opts *CommandOptions, args []string, out io.Writer) bool {
-	tp := parseType(opts.Type, c.Dialect())
+	if opts.ExplainData || opts.ExplainOptions {
+		tp, dv, options := lookupArguments(c, opts)
+		explainer := explain.NewExplainer(opts.ExplainOptions, opts.ExplainOptions && !opts.ExplainData)
+		value := lookupForRender(c.Invocation(createScope(c, opts), explainer), opts, args, tp, dv, options)
+		renderAs := Text
+		if opts.RenderAs != `` {
+			renderAs = RenderName(opts.RenderAs)
+		}
+		Render(c, renderAs, explainer, out)
+		return value != nil
+	}
+
+	value := LookupValue(c, opts, args)
+	if value == nil {
+		return false
+	}
+
+	renderAs := YAML
+	if opts.RenderAs != `` {
+		renderAs = RenderName(opts.RenderAs)
+	}
+	Render(c, renderAs, value, out)
+	return true
+}
 
-	var options dgo.Map
-	if !(opts.Merge == `` || opts.Merge == `first`) {
-		options = vf.Map(`merge`, opts.Merge)
+// LookupValue performs a lookup using the given command options and arguments and returns the value that
+// LookupAndRender would render, or nil if no value is found. The explain and render options are ignored.
+func LookupValue(c api.Session, opts *CommandOptions, args []string) (value dgo.Value) {
+	if len(args) > 0 && metrics.Enabled() {
+		defer observeLookup(args[0], time.Now(), &value)
+	}
+	tp, dv, options := lookupArguments(c, opts)
+	return lookupForRender(c.Invocation(createScope(c, opts), nil), opts, args, tp, dv, options)
+}
+
+// observeLookup records the outcome of a lookup of the given key in the metrics. It must be deferred so that it can
+// record a panic as an error.
+func observeLookup(key string, start time.Time, value *dgo.Value) {
+	root := keyRoot(key)
+	if r := recover(); r != nil {
+		metrics.ObserveLookup(root, metrics.Error, start)
+		panic(r)
+	}
+	if *value != nil {
+		metrics.ObserveLookup(root, metrics.Found, start)
+	} else {
+		metrics.ObserveLookup(root, metrics.NotFound, start)
+	}
+}
+
+// keyRoot returns the root of the given key, or the key itself if it cannot be parsed
+func keyRoot(key string) (root string) {
+	if util.Catch(func() { root = api.NewKey(key).Root() }) != nil {
+		root = key
+	}
+	return
+}
+
+// Explain performs a lookup using the given command options and arguments and returns a map that explains it. The
+// map has a "text" entry with the explanation rendered as text, an "explanation" entry with the explainer itself
+// which renders as a tree of explainer nodes, and a "found" entry that tells if a value was found. The found value
+// is in a "value" entry.
+//
+// The data lookup is always explained. The lookups of lookup_options are also explained when the ExplainOptions
+// option is set.
+func Explain(c api.Session, opts *CommandOptions, args []string) dgo.Map {
+	tp, dv, options := lookupArguments(c, opts)
+	explainer := explain.NewExplainer(opts.ExplainOptions, false)
+	found := lookupForRender(c.Invocation(createScope(c, opts), explainer), opts, args, tp, dv, options)
+	result := vf.MapWithCapacity(4)
+	result.Put(`found`, found != nil)
+	if found != nil {
+		result.Put(`value`, found)
 	}
+	result.Put(`text`, explainer.String())
+	result.Put(`explanation`, explainer)
+	return result
+}
 
-	var dv dgo.Value
+// lookupArguments returns the value type, the default value, and the options map of the lookup described by the
+// given command options
+func lookupArguments(c api.Session, opts *CommandOptions) (tp dgo.Type, dv dgo.Value, options dgo.Map) {
+	tp = parseType(c, opts.Type)
+	mo, err := mergeOptions(opts)
+	if err != nil {
+		panic(err)
+	}
+	if mo != nil {
+		options = vf.Map(`merge`, mo)
+	}
 	if opts.Default != nil {
 		s := *opts.Default
 		if s == `` {
@@ -227,48 +329,94 @@ func LookupAndRender(c api.Session, opts *CommandOptions, args []string, out io.
 			dv = parseCommandLineValue(c, s)
 		}
 	}
+	return
+}
 
-	var explainer api.Explainer
-	if opts.ExplainData || opts.ExplainOptions {
-		explainer = explain.NewExplainer(opts.ExplainOptions, opts.ExplainOptions && !opts.ExplainData)
-	}
-
-	var found dgo.Value
-	invocation := c.Invocation(createScope(c, opts), explainer)
+// lookupForRender performs the lookup for LookupAndRender. The result is a map with the entries "value" and
+// "provenance" when the provenance option is set.
+func lookupForRender(
+	invocation api.Invocation,
+	opts *CommandOptions,
+	args []string,
+	tp dgo.Type,
+	dv dgo.Value,
+	options dgo.Map) dgo.Value {
 	if opts.LookupAll {
+		if opts.Provenance {
+			panic(errors.New(`the provenance option cannot be combined with the lookup all option`))
+		}
 		stp, ok := tp.(dgo.StructMapType)
 		if !ok && opts.Type != `` {
 			panic(fmt.Errorf("type must be a map"))
 		}
-		found = LookupAll(invocation, args, stp, nil, nil, options)
-	} else {
-		found = Lookup2(invocation, args, tp, dv, nil, nil, options, nil)
+		return LookupAll(invocation, args, stp, nil, nil, options)
 	}
-	if explainer != nil {
-		renderAs := Text
-		if opts.RenderAs != `` {
-			renderAs = RenderName(opts.RenderAs)
+	if opts.Provenance {
+		if invocation.ExplainMode() {
+			panic(errors.New(`the provenance option cannot be combined with the explain options`))
 		}
-		Render(c, renderAs, explainer, out)
-		return found != nil
+		v, p := lookupWithProvenance(invocation, args, tp, dv, options)
+		if v == nil {
+			return nil
+		}
+		return vf.Map(`value`, v, `provenance`, p)
 	}
+	return Lookup2(invocation, args, tp, dv, nil, nil, options, nil)
+}
 
-	if found == nil {
-		return false
+// ValidateMergeOptions returns an error if the merge strategy is not registered or if the knockout prefix, sort merged
+// arrays, or merge hash arrays options are given without merge 'deep'.
+func ValidateMergeOptions(opts *CommandOptions) error {
+	_, err := mergeOptions(opts)
+	return err
+}
+
+// ValidateType returns an error if the type of the given options cannot be parsed using the dialect of the given
+// session.
+func ValidateType(c api.Session, opts *CommandOptions) error {
+	if err := util.Catch(func() { parseType(c, opts.Type) }); err != nil {
+		return fmt.Errorf(`invalid type '%s': %s`, opts.Type, err.Error())
 	}
+	return nil
+}
 
-	renderAs := YAML
-	if opts.RenderAs != `` {
-		renderAs = RenderName(opts.RenderAs)
+// mergeOptions returns the merge option to use for the lookup or nil when the default merge strategy applies. The
+// returned value is a map with a "strategy" key and the deep merge options, or just the strategy name when no deep
+// merge options were given. An error is returned when the strategy is not registered or when deep merge options are
+// given with another strategy.
+func mergeOptions(opts *CommandOptions) (dgo.Value, error) {
+	if opts.Merge != `` && !merge.IsRegistered(opts.Merge) {
+		return nil, fmt.Errorf(`unknown merge strategy '%s'`, opts.Merge)
 	}
-	Render(c, renderAs, found, out)
-	return true
+	deepOpts := vf.MutableMap()
+	if opts.KnockoutPrefix != `` {
+		deepOpts.Put(`knockout_prefix`, opts.KnockoutPrefix)
+	}
+	if opts.SortMergedArrays {
+		deepOpts.Put(`sort_merged_arrays`, true)
+	}
+	if opts.MergeHashArrays {
+		deepOpts.Put(`merge_hash_arrays`, true)
+	}
+	if deepOpts.Len() > 0 {
+		if opts.Merge != `deep` {
+			return nil, errors.New(`the knockout prefix, sort merged arrays, and merge hash arrays options are only valid with merge 'deep'`)
+		}
+		deepOpts.Put(`strategy`, opts.Merge)
+		return deepOpts, nil
+	}
+	if opts.Merge == `` || opts.Merge == `first` {
+		return nil, nil
+	}
+	return vf.String(opts.Merge), nil
 }
 
-func parseType(t string, dl streamer.Dialect) dgo.Type {
+func parseType(c api.Session, t string) dgo.Type {
 	tp := typ.Any
 	if t != `` {
-		tp = dl.ParseType(nil, vf.String(t))
+		c.AliasMap().Collect(func(aa dgo.AliasAdder) {
+			tp = c.Dialect().ParseType(aa, vf.String(t))
+		})
 	}
 	return tp
 }
@@ -284,6 +432,9 @@ func parseCommandLineValue(c api.Session, vs string) dgo.Value {
 			return v
 		}
 	}
+	if v := api.ParseScalar(vs); v != nil {
+		return v
+	}
 	return vf.String(vs)
 }
 
@@ -307,6 +458,9 @@ func createScope(c api.Session, opts *CommandOptions) dgo.Map {
 		scope.PutAll(facts)
 		scope.Put(`facts`, facts)
 	}
+	if opts.Trusted != nil {
+		scope.Put(`trusted`, opts.Trusted)
+	}
 	return scope
 }
 
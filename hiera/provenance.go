@@ -0,0 +1,130 @@
+package hiera
+
+import (
+	"strconv"
+
+	"github.com/lyraproj/dgo/dgo"
+	"github.com/lyraproj/dgo/typ"
+	"github.com/lyraproj/dgo/vf"
+	"github.com/lyraproj/hiera/api"
+)
+
+// LookupWithProvenance performs a lookup using the given parameters in the same way as Lookup and returns the
+// found value together with its provenance.
+//
+// The provenance is a tree that parallels the found value. Each hash in the value has a corresponding hash in the
+// provenance. All other values are represented by a hash with the "level" and "provider" of the hierarchy level
+// that supplied the value and, when applicable, the resolved "location". An array whose elements were supplied by
+// several levels is represented by an array with the provenance of each element. A value that was produced from
+// several levels in a way that cannot be attributed to its parts, such as by a custom merge strategy or by a
+// convert_to conversion, is represented by a hash with the "sources" key set to an array with one such hash per level.
+//
+// The provenance is nil when no value was found or when the default value was used.
+//
+// ic - The lookup invocation
+//
+// name - The name to lookup
+//
+// defaultValue - Optional value to use as default when no value is found
+//
+// options - Optional map with merge strategy and options
+func LookupWithProvenance(ic api.Invocation, name string, defaultValue dgo.Value, options interface{}) (dgo.Value, dgo.Value) {
+	return lookupWithProvenance(ic, []string{name}, typ.Any, defaultValue, api.ToMap(`lookup options`, options))
+}
+
+func lookupWithProvenance(
+	ic api.Invocation,
+	names []string,
+	valueType dgo.Type,
+	defaultValue dgo.Value,
+	options dgo.Map) (dgo.Value, dgo.Value) {
+	for _, name := range names {
+		key := api.NewKey(name)
+		v, p := ic.RecordProvenance(key, func() dgo.Value { return ic.Lookup(key, options) })
+		if v != nil {
+			return ensureType(valueType, v), provenanceOf(v, digProvenance(p, key.Parts()[1:]))
+		}
+	}
+	if defaultValue != nil {
+		return ensureType(valueType, defaultValue), nil
+	}
+	return nil, nil
+}
+
+// digProvenance returns the provenance of the part of a value with the given provenance that is found at the given
+// path. A part of a value that stems from one source as a whole stems from that source too.
+func digProvenance(p *api.Provenance, path []interface{}) *api.Provenance {
+	for _, k := range path {
+		if p == nil {
+			break
+		}
+		switch k := k.(type) {
+		case string:
+			if p.Entries != nil {
+				p = p.Entries[k]
+			}
+		case int:
+			switch {
+			case p.Entries != nil:
+				p = p.Entries[strconv.Itoa(k)]
+			case k >= 0 && k < len(p.Elements):
+				p = p.Elements[k]
+			}
+		}
+	}
+	return p
+}
+
+// provenanceOf returns the representation of the given provenance of the value v.
+func provenanceOf(v dgo.Value, p *api.Provenance) dgo.Value {
+	if p == nil {
+		return vf.Nil
+	}
+	switch vt := v.(type) {
+	case dgo.Map:
+		pm := vf.MapWithCapacity(vt.Len())
+		vt.EachEntry(func(e dgo.MapEntry) {
+			ep := p
+			if p.Entries != nil {
+				ep = p.Entries[e.Key().String()]
+			}
+			pm.Put(e.Key(), provenanceOf(e.Value(), ep))
+		})
+		return pm
+	case dgo.Array:
+		if len(p.Elements) == vt.Len() && len(p.AllSources()) > 1 {
+			pa := vf.ArrayWithCapacity(vt.Len())
+			vt.EachWithIndex(func(e dgo.Value, i int) {
+				pa.Add(provenanceOf(e, p.Elements[i]))
+			})
+			return pa
+		}
+	}
+	return sourcesInfo(p.AllSources())
+}
+
+// sourcesInfo returns the representation of the given sources of a value
+func sourcesInfo(sources []*api.Source) dgo.Value {
+	switch len(sources) {
+	case 0:
+		return vf.Nil
+	case 1:
+		return sourceInfo(sources[0])
+	default:
+		infos := vf.ArrayWithCapacity(len(sources))
+		for _, s := range sources {
+			infos.Add(sourceInfo(s))
+		}
+		return vf.Map(`sources`, infos)
+	}
+}
+
+func sourceInfo(s *api.Source) dgo.Map {
+	info := vf.MapWithCapacity(3)
+	info.Put(`level`, s.Provider.Hierarchy().Name())
+	info.Put(`provider`, s.Provider.FullName())
+	if s.Location != nil {
+		info.Put(`location`, s.Location.Resolved())
+	}
+	return info
+}
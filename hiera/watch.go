@@ -0,0 +1,111 @@
+package hiera
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/lyraproj/hiera/api"
+	log "github.com/sirupsen/logrus"
+)
+
+type watcher struct {
+	onChange  func()
+	fw        *fsnotify.Watcher
+	delay     time.Duration
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// WatchAndReload watches the given directories and all of their subdirectories and calls Reload on the given session
+// when a file is created, written, removed, or renamed. The directory of the hiera.yaml of the session is watched
+// when no directories are given. Changes that occur within the given delay of the first change are collected into
+// one reload so that a reload doesn't happen in the middle of a deployment of several files.
+//
+// The returned io.Closer stops the watching.
+func WatchAndReload(s api.Session, delay time.Duration, dirs ...string) (io.Closer, error) {
+	if len(dirs) == 0 {
+		dirs = []string{filepath.Dir(s.SessionOptions().Get(api.HieraConfig).String())}
+	}
+	return Watch(delay, s.Reload, dirs...)
+}
+
+// Watch watches the given directories and all of their subdirectories and calls the given function when a file is
+// created, written, removed, or renamed. Changes are collected the same way as in WatchAndReload.
+//
+// The returned io.Closer stops the watching.
+func Watch(delay time.Duration, onChange func(), dirs ...string) (io.Closer, error) {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	w := &watcher{onChange: onChange, fw: fw, delay: delay, done: make(chan struct{})}
+	for _, dir := range dirs {
+		if err = w.addTree(dir); err != nil {
+			_ = fw.Close()
+			return nil, err
+		}
+	}
+	go w.run()
+	return w, nil
+}
+
+// addTree adds the given directory and all of its subdirectories to the watcher
+func (w *watcher) addTree(dir string) error {
+	return filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			return w.fw.Add(path)
+		}
+		return nil
+	})
+}
+
+func (w *watcher) run() {
+	var reload <-chan time.Time
+	for {
+		select {
+		case <-w.done:
+			return
+		case ev, ok := <-w.fw.Events:
+			if !ok {
+				return
+			}
+			if ev.Op == fsnotify.Chmod {
+				continue
+			}
+			if ev.Op&fsnotify.Create != 0 {
+				if fi, err := os.Stat(ev.Name); err == nil && fi.IsDir() {
+					if err = w.addTree(ev.Name); err != nil {
+						log.Errorf(`unable to watch %s: %s`, ev.Name, err.Error())
+					}
+				}
+			}
+			if reload == nil {
+				reload = time.After(w.delay)
+			}
+		case err, ok := <-w.fw.Errors:
+			if !ok {
+				return
+			}
+			log.Errorf(`error while watching hiera files: %s`, err.Error())
+		case <-reload:
+			reload = nil
+			w.onChange()
+			log.Info(`hiera files changed, reloaded`)
+		}
+	}
+}
+
+func (w *watcher) Close() (err error) {
+	w.closeOnce.Do(func() {
+		close(w.done)
+		err = w.fw.Close()
+	})
+	return
+}
@@ -0,0 +1,274 @@
+// Package acl contains the access control list that the Hiera REST server uses to decide what keys a client may
+// look up
+package acl
+
+import (
+	"crypto/subtle"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/lyraproj/dgo/dgo"
+	"github.com/lyraproj/dgo/util"
+	"github.com/lyraproj/hiera/api"
+	"gopkg.in/yaml.v3"
+)
+
+// AnySubject is the subject that matches all verified client certificates
+const AnySubject = `*`
+
+// Endpoints that a rule can grant access to
+const (
+	// ReloadEndpoint is the endpoint that reloads the configurations and data of the server
+	ReloadEndpoint = `reload`
+
+	// MetricsEndpoint is the endpoint that exposes the metrics of the server
+	MetricsEndpoint = `metrics`
+)
+
+// An ACL is a list of rules. A client may look up a key when one of the rules that match the client allows it.
+type ACL struct {
+	Rules []*Rule `yaml:"rules"`
+}
+
+// A Rule grants the clients that it matches access to keys
+type Rule struct {
+	// Subjects match the verified client certificate of a request. A subject matches when it is equal to the common
+	// name or the distinguished name of the certificate, such as "CN=web01,O=Example". The subject "*" matches all
+	// verified certificates.
+	Subjects []string `yaml:"subjects"`
+
+	// Tokens match the bearer token in the Authorization header of a request
+	Tokens []string `yaml:"tokens"`
+
+	// Keys are patterns that match the keys that the rule grants access to. The patterns use the syntax of
+	// path.Match. A key is granted when a pattern matches the key or one of its parents, so "aws" grants access to
+	// "aws" and "aws.tags" while "aws.*" grants access to "aws.tags" but not to "aws". The patterns are matched
+	// against the parsed key, so the key `"aws".tags` is the same as "aws.tags", the key `"aws.tags"` has the single
+	// segment "aws.tags", and the key "aws.01" is the same as "aws.1".
+	Keys []string `yaml:"keys"`
+
+	// Endpoints contains the names of the administrative endpoints that the rule grants access to, i.e. "reload" and
+	// "metrics"
+	Endpoints []string `yaml:"endpoints"`
+
+	// Merge contains the names of the merge strategies that may be used. All strategies may be used when it is empty.
+	Merge []string `yaml:"merge"`
+
+	// Sensitive grants access to values that are Sensitive. Such values are denied by default.
+	Sensitive bool `yaml:"sensitive"`
+}
+
+// Load reads an ACL from the YAML file at the given path
+func Load(path string) (*ACL, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	a, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf(`%s: %s`, path, err.Error())
+	}
+	return a, nil
+}
+
+// Parse parses an ACL from the given YAML
+func Parse(data []byte) (*ACL, error) {
+	a := &ACL{}
+	if err := yaml.Unmarshal(data, a); err != nil {
+		return nil, err
+	}
+	for i, r := range a.Rules {
+		if r == nil || len(r.Subjects) == 0 && len(r.Tokens) == 0 {
+			return nil, fmt.Errorf(`rule %d has neither subjects nor tokens`, i+1)
+		}
+		if len(r.Keys) == 0 && len(r.Endpoints) == 0 {
+			return nil, fmt.Errorf(`rule %d has neither keys nor endpoints`, i+1)
+		}
+		for _, e := range r.Endpoints {
+			if e != ReloadEndpoint && e != MetricsEndpoint {
+				return nil, fmt.Errorf(`rule %d has an unknown endpoint '%s'`, i+1, e)
+			}
+		}
+		for _, k := range r.Keys {
+			if _, err := path.Match(k, ``); err != nil {
+				return nil, fmt.Errorf(`rule %d has an invalid key pattern '%s': %s`, i+1, k, err.Error())
+			}
+		}
+	}
+	return a, nil
+}
+
+// Authorize returns an error unless a rule that matches the client of the given request grants access to the given
+// key using the given merge strategy. An empty merge strategy is the same as "first".
+func (a *ACL) Authorize(r *http.Request, key, merge string) error {
+	if merge == `` {
+		merge = `first`
+	}
+	rules := a.matchingRules(r)
+	if len(rules) == 0 {
+		return errors.New(`access denied: no rule matches the client`)
+	}
+	paths, err := keyPaths(key)
+	if err != nil {
+		return fmt.Errorf(`access denied to key '%s': %s`, key, err.Error())
+	}
+	keyGranted := false
+	for _, rule := range rules {
+		if rule.grantsKey(paths) {
+			keyGranted = true
+			if rule.grantsMerge(merge) {
+				return nil
+			}
+		}
+	}
+	if keyGranted {
+		return fmt.Errorf(`access denied: merge strategy '%s' is not granted for key '%s'`, merge, key)
+	}
+	return fmt.Errorf(`access denied to key '%s'`, key)
+}
+
+// AuthorizeSensitive returns an error unless a rule that matches the client of the given request grants access to
+// the Sensitive value of the given key
+func (a *ACL) AuthorizeSensitive(r *http.Request, key string) error {
+	paths, err := keyPaths(key)
+	if err != nil {
+		return fmt.Errorf(`access denied to sensitive value of key '%s': %s`, key, err.Error())
+	}
+	for _, rule := range a.matchingRules(r) {
+		if rule.Sensitive && rule.grantsKey(paths) {
+			return nil
+		}
+	}
+	return fmt.Errorf(`access denied to sensitive value of key '%s'`, key)
+}
+
+// AuthorizeEndpoint returns an error unless a rule that matches the client of the given request grants access to the
+// given endpoint
+func (a *ACL) AuthorizeEndpoint(r *http.Request, endpoint string) error {
+	for _, rule := range a.matchingRules(r) {
+		for _, e := range rule.Endpoints {
+			if e == endpoint {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf(`access denied to endpoint '%s'`, endpoint)
+}
+
+// ContainsSensitive returns true if the given value is Sensitive or is a map or array that contains a Sensitive value
+func ContainsSensitive(v dgo.Value) bool {
+	switch v := v.(type) {
+	case dgo.Sensitive:
+		return true
+	case dgo.Map:
+		return v.Find(func(e dgo.MapEntry) bool {
+			return ContainsSensitive(e.Key()) || ContainsSensitive(e.Value())
+		}) != nil
+	case dgo.Array:
+		return v.Find(func(e dgo.Value) interface{} {
+			if ContainsSensitive(e) {
+				return e
+			}
+			return nil
+		}) != nil
+	}
+	return false
+}
+
+func (a *ACL) matchingRules(r *http.Request) []*Rule {
+	cert := verifiedCertificate(r)
+	token := bearerToken(r)
+	var rules []*Rule
+	for _, rule := range a.Rules {
+		if rule.matchesCertificate(cert) || rule.matchesToken(token) {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+func verifiedCertificate(r *http.Request) *x509.Certificate {
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+		return r.TLS.VerifiedChains[0][0]
+	}
+	return nil
+}
+
+func bearerToken(r *http.Request) string {
+	const prefix = `Bearer `
+	if h := r.Header.Get(`Authorization`); len(h) > len(prefix) && strings.EqualFold(h[:len(prefix)], prefix) {
+		return strings.TrimSpace(h[len(prefix):])
+	}
+	return ``
+}
+
+func (rule *Rule) matchesCertificate(cert *x509.Certificate) bool {
+	if cert == nil {
+		return false
+	}
+	for _, s := range rule.Subjects {
+		if s == AnySubject || s == cert.Subject.CommonName || s == cert.Subject.String() {
+			return true
+		}
+	}
+	return false
+}
+
+func (rule *Rule) matchesToken(token string) bool {
+	if token == `` {
+		return false
+	}
+	for _, t := range rule.Tokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// keyPaths parses the given key and returns the path of its root followed by the paths of its nested keys. The
+// segments of a path are separated by a dot and index segments are written as decimal numbers.
+func keyPaths(key string) (paths []string, err error) {
+	var parts []interface{}
+	if err = util.Catch(func() { parts = api.NewKey(key).Parts() }); err != nil {
+		return nil, err
+	}
+	paths = make([]string, len(parts))
+	for i, p := range parts {
+		ps := fmt.Sprint(p)
+		if i > 0 {
+			ps = paths[i-1] + `.` + ps
+		}
+		paths[i] = ps
+	}
+	return paths, nil
+}
+
+// grantsKey returns true if one of the key patterns of the rule matches one of the given key paths
+func (rule *Rule) grantsKey(paths []string) bool {
+	for _, p := range rule.Keys {
+		for _, k := range paths {
+			if ok, _ := path.Match(p, k); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (rule *Rule) grantsMerge(merge string) bool {
+	if len(rule.Merge) == 0 {
+		return true
+	}
+	for _, m := range rule.Merge {
+		if m == merge {
+			return true
+		}
+	}
+	return false
+}
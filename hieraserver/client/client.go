@@ -0,0 +1,225 @@
+// Package client contains a client for the REST API of the Hiera server
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lyraproj/dgo/dgo"
+	"github.com/lyraproj/dgo/streamer"
+	"github.com/lyraproj/dgo/util"
+	"github.com/lyraproj/dgo/vf"
+	"github.com/lyraproj/hiera/api"
+)
+
+// Config contains the settings used when creating a Client
+type Config struct {
+	// BaseURL is the URL of the server, e.g. "https://hiera.example.com:8080"
+	BaseURL string
+
+	// CertFile and KeyFile appoint the PEM encoded client certificate and its private key. They are needed when
+	// the server is started with --clientCertVerify
+	CertFile string
+	KeyFile  string
+
+	// CAFile appoints a file with PEM encoded certificates used to verify the server certificate. The system
+	// certificates are used when it is empty
+	CAFile string
+
+	// Timeout is the timeout of each request. No timeout is used when it is zero
+	Timeout time.Duration
+
+	// HTTPClient is used instead of a client created from the TLS settings and the timeout when it is not nil
+	HTTPClient *http.Client
+}
+
+// Options are the options of a lookup. They correspond to the query parameters of the /lookup endpoint.
+type Options struct {
+	// Merge is the name of a merge strategy
+	Merge string
+
+	// KnockoutPrefix is the knockout_prefix to use with the deep merge strategy
+	KnockoutPrefix string
+
+	// SortMergedArrays should be set to true to sort arrays merged by the deep merge strategy
+	SortMergedArrays bool
+
+	// MergeHashArrays should be set to true to let the deep merge strategy merge arrays of hashes
+	MergeHashArrays bool
+
+	// Type is a type string such as "string" or "[]int" used for assertion of the found value
+	Type string
+
+	// Default is the value to return when no value is found. No default is used when it is nil
+	Default interface{}
+
+	// Variables are added to the scope of the lookup
+	Variables map[string]interface{}
+}
+
+// NotFoundError is returned when the server finds no value for the key
+type NotFoundError struct {
+	Key string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf(`value for key '%s' not found`, e.Key)
+}
+
+// ServerError is returned when the server responds with a status other than 200 OK or 404 Not Found. A failing
+// lookup results in a 500 Internal Server Error and invalid options in a 400 Bad Request.
+type ServerError struct {
+	Key        string
+	StatusCode int
+	Message    string
+}
+
+func (e *ServerError) Error() string {
+	return fmt.Sprintf(`lookup of key '%s' failed with status %d: %s`, e.Key, e.StatusCode, e.Message)
+}
+
+// A Client performs lookups using the REST API of a Hiera server
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// New creates a new Client from the given configuration
+func New(cfg Config) (*Client, error) {
+	if _, err := url.Parse(cfg.BaseURL); err != nil {
+		return nil, err
+	}
+	hc := cfg.HTTPClient
+	if hc == nil {
+		tc, err := makeTLSConfig(&cfg)
+		if err != nil {
+			return nil, err
+		}
+		var rt http.RoundTripper = http.DefaultTransport
+		if tc != nil {
+			t := http.DefaultTransport.(*http.Transport).Clone()
+			t.TLSClientConfig = tc
+			rt = t
+		}
+		hc = &http.Client{Transport: rt, Timeout: cfg.Timeout}
+	}
+	return &Client{baseURL: strings.TrimSuffix(cfg.BaseURL, `/`), httpClient: hc}, nil
+}
+
+// Lookup looks up the given key and returns the found value. A *NotFoundError is returned when no value is found.
+func (c *Client) Lookup(ctx context.Context, key string, opts *Options) (dgo.Value, error) {
+	bs, err := c.lookup(ctx, key, opts)
+	if err != nil {
+		return nil, err
+	}
+	var v dgo.Value
+	err = util.Catch(func() { v = streamer.UnmarshalJSON(bs, nil) })
+	return v, err
+}
+
+// LookupInto looks up the given key and decodes the found value into the value pointed to by dest using the
+// rules of json.Unmarshal. A *NotFoundError is returned when no value is found.
+func (c *Client) LookupInto(ctx context.Context, key string, opts *Options, dest interface{}) error {
+	bs, err := c.lookup(ctx, key, opts)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(bs, dest)
+}
+
+func (c *Client) lookup(ctx context.Context, key string, opts *Options) ([]byte, error) {
+	u := c.baseURL + `/lookup/` + url.PathEscape(key)
+	if opts != nil {
+		var q url.Values
+		if err := util.Catch(func() { q = opts.query() }); err != nil {
+			return nil, err
+		}
+		if len(q) > 0 {
+			u += `?` + q.Encode()
+		}
+	}
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	bs, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return bs, nil
+	case http.StatusNotFound:
+		return nil, &NotFoundError{Key: key}
+	default:
+		return nil, &ServerError{Key: key, StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(bs))}
+	}
+}
+
+func (o *Options) query() url.Values {
+	q := url.Values{}
+	if o.Merge != `` {
+		q.Set(`merge`, o.Merge)
+	}
+	if o.KnockoutPrefix != `` {
+		q.Set(`knockout_prefix`, o.KnockoutPrefix)
+	}
+	if o.SortMergedArrays {
+		q.Set(`sort_merged_arrays`, strconv.FormatBool(true))
+	}
+	if o.MergeHashArrays {
+		q.Set(`merge_hash_arrays`, strconv.FormatBool(true))
+	}
+	if o.Type != `` {
+		q.Set(`type`, o.Type)
+	}
+	if o.Default != nil {
+		q.Set(`default`, api.EncodeValue(vf.Value(o.Default)))
+	}
+	for k, v := range o.Variables {
+		q.Add(`var`, k+`=`+api.EncodeValue(vf.Value(v)))
+	}
+	return q
+}
+
+func makeTLSConfig(cfg *Config) (*tls.Config, error) {
+	if cfg.CertFile == `` && cfg.KeyFile == `` && cfg.CAFile == `` {
+		return nil, nil
+	}
+	tlsConfig := new(tls.Config)
+	if cfg.CertFile != `` || cfg.KeyFile != `` {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if cfg.CAFile != `` {
+		data, err := ioutil.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		certPool := x509.NewCertPool()
+		if !certPool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("failed to load certificate %q", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = certPool
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,210 @@
+// Package environments contains the pool of sessions that the Hiera REST server uses to serve several hiera roots,
+// one per environment, Puppet-style. Each environment is a directory with a hiera.yaml under a common environments
+// directory. Sessions are created when an environment is first used and closed when it has been idle for a while.
+package environments
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lyraproj/hiera/api"
+	"github.com/lyraproj/hiera/config"
+	"github.com/lyraproj/hiera/session"
+	"github.com/lyraproj/hierasdk/hiera"
+)
+
+// validName is the pattern that the name of an environment must match. It is the same pattern that Puppet uses and it
+// prevents the name from appointing anything outside of the environments directory.
+var validName = regexp.MustCompile(`\A[a-z0-9_]+\z`)
+
+// NotFoundError is returned when an environment doesn't exist
+type NotFoundError struct {
+	Name string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf(`environment '%s' not found`, e.Name)
+}
+
+type entry struct {
+	session  api.Session
+	active   int
+	lastUsed time.Time
+}
+
+// A Pool creates and caches one session per environment
+type Pool struct {
+	parent      context.Context
+	dir         string
+	topProvider hiera.LookupKey
+	options     map[string]interface{}
+	idleTimeout time.Duration
+	lock        sync.Mutex
+	entries     map[string]*entry
+	done        chan struct{}
+	closeOnce   sync.Once
+}
+
+// NewPool creates a pool of sessions for the environments found in the given directory. An environment is a
+// subdirectory that contains a hiera.yaml. The sessions are created using the given parent context, top provider, and
+// options. The api.HieraConfig option of each session is set to the hiera.yaml of its environment.
+//
+// A session that hasn't been used during the given idle timeout is closed and its plugins are killed. Sessions are
+// never closed when the timeout is zero. The returned pool must be closed when it is no longer used.
+func NewPool(parent context.Context, dir string, tp hiera.LookupKey, options map[string]interface{}, idleTimeout time.Duration) *Pool {
+	p := &Pool{
+		parent:      parent,
+		dir:         dir,
+		topProvider: tp,
+		options:     options,
+		idleTimeout: idleTimeout,
+		entries:     map[string]*entry{},
+		done:        make(chan struct{})}
+	if idleTimeout > 0 {
+		go p.reapIdle()
+	}
+	return p
+}
+
+// Dir returns the environments directory of the pool
+func (p *Pool) Dir() string {
+	return p.dir
+}
+
+// Do calls the given function with the session of the environment with the given name. The session is created if it
+// doesn't exist. A *NotFoundError is returned if the environments directory has no environment with the given name.
+// The session is never closed while the function runs.
+func (p *Pool) Do(name string, doer func(api.Session)) error {
+	e, err := p.acquire(name)
+	if err != nil {
+		return err
+	}
+	defer p.release(e)
+	doer(e.session)
+	return nil
+}
+
+func (p *Pool) acquire(name string) (*entry, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	e, ok := p.entries[name]
+	if !ok {
+		configPath, err := p.ConfigFile(name)
+		if err != nil {
+			return nil, err
+		}
+		options := make(map[string]interface{}, len(p.options)+1)
+		for k, v := range p.options {
+			options[k] = v
+		}
+		options[api.HieraConfig] = configPath
+		e = &entry{session: session.New(p.parent, p.topProvider, options, nil)}
+		p.entries[name] = e
+	}
+	e.active++
+	return e, nil
+}
+
+// ConfigFile returns the path of the hiera.yaml of the environment with the given name without creating a session
+// for it. A *NotFoundError is returned if the environments directory has no environment with the given name.
+func (p *Pool) ConfigFile(name string) (string, error) {
+	if !validName.MatchString(name) {
+		return ``, &NotFoundError{Name: name}
+	}
+	configPath := filepath.Join(p.dir, name, config.FileName)
+	if fi, err := os.Stat(configPath); err != nil || fi.IsDir() {
+		return ``, &NotFoundError{Name: name}
+	}
+	return configPath, nil
+}
+
+func (p *Pool) release(e *entry) {
+	p.lock.Lock()
+	e.active--
+	e.lastUsed = time.Now()
+	p.lock.Unlock()
+}
+
+// Names returns the sorted names of the environments that currently have a session
+func (p *Pool) Names() []string {
+	p.lock.Lock()
+	names := make([]string, 0, len(p.entries))
+	for n := range p.entries {
+		names = append(names, n)
+	}
+	p.lock.Unlock()
+	sort.Strings(names)
+	return names
+}
+
+// Available returns the sorted names of all environments in the environments directory, whether they have a session
+// or not
+func (p *Pool) Available() ([]string, error) {
+	fis, err := ioutil.ReadDir(p.dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, fi := range fis {
+		name := fi.Name()
+		if !fi.IsDir() || !validName.MatchString(name) {
+			continue
+		}
+		if cfi, err := os.Stat(filepath.Join(p.dir, name, config.FileName)); err == nil && !cfi.IsDir() {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// Reload calls Reload on the sessions of all environments
+func (p *Pool) Reload() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for _, e := range p.entries {
+		e.session.Reload()
+	}
+}
+
+// Reap closes the sessions that aren't in use and that were last used before the given time
+func (p *Pool) Reap(before time.Time) {
+	p.lock.Lock()
+	var reaped []api.Session
+	for n, e := range p.entries {
+		if e.active == 0 && e.lastUsed.Before(before) {
+			delete(p.entries, n)
+			reaped = append(reaped, e.session)
+		}
+	}
+	p.lock.Unlock()
+	for _, s := range reaped {
+		s.KillPlugins()
+	}
+}
+
+func (p *Pool) reapIdle() {
+	ticker := time.NewTicker(p.idleTimeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.done:
+			return
+		case now := <-ticker.C:
+			p.Reap(now.Add(-p.idleTimeout))
+		}
+	}
+}
+
+// Close stops the reaping of idle sessions and closes the sessions of all environments that aren't in use
+func (p *Pool) Close() error {
+	p.closeOnce.Do(func() { close(p.done) })
+	p.Reap(time.Now())
+	return nil
+}
@@ -1,22 +1,27 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"crypto/tls"
 	"crypto/x509"
-	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"os"
-	"regexp"
+	"os/signal"
 	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/lyraproj/hiera/config"
 
 	"github.com/lyraproj/hiera/api"
 	"github.com/lyraproj/hiera/hiera"
+	"github.com/lyraproj/hiera/hieraserver/acl"
+	"github.com/lyraproj/hiera/hieraserver/environments"
+	"github.com/lyraproj/hiera/hieraserver/router"
+	"github.com/lyraproj/hiera/hieraserver/trusted"
+	"github.com/lyraproj/hiera/metrics"
 	"github.com/lyraproj/hiera/provider"
 	sdk "github.com/lyraproj/hierasdk/hiera"
 	"github.com/spf13/cobra"
@@ -39,16 +44,28 @@ var (
 	sslCert          string
 	clientCA         string
 	clientCertVerify bool
-	cmdOpts          hiera.CommandOptions
+	watch            bool
+	aclPath          string
+	extensionFlags   []string
+	environmentsDir  string
+	environmentIdle  time.Duration
+	routerConfig     router.Config
 	port             int
 )
 
+// watchDelay is the time that the server waits for more changes after a watched file has changed before it reloads
+const watchDelay = 500 * time.Millisecond
+
 func newCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "server",
 		Short: `Server - Start a Hiera REST server`,
 		Long: `Server - Start a REST server that performs lookups in a Hiera data storage.
-  Responds to key lookups under the /lookup endpoint`,
+  Responds to key lookups under the /lookup endpoint, to batch lookups posted to /lookup, and explains
+  lookups under the /explain endpoint. The same endpoints are served for each environment under /env/<name>
+  when --environments is given. The /healthz, /readyz, and /metrics endpoints are intended for
+  orchestrators and monitoring. Cached configurations and data are discarded on a POST to /reload, on
+  SIGHUP, and, when --watch is given, when files in the directory of the hiera config file change`,
 		Run:  startServer,
 		Args: cobra.NoArgs}
 
@@ -57,9 +74,9 @@ func newCommand() *cobra.Command {
 		`error/warn/info/debug`)
 	flags.StringVar(&configPath, `config`, `/hiera/`+config.FileName,
 		`path to the hiera config file. Overrides /hiera/`+config.FileName)
-	flags.StringArrayVar(&cmdOpts.VarPaths, `vars`, nil,
+	flags.StringArrayVar(&routerConfig.Options.VarPaths, `vars`, nil,
 		`path to a JSON or YAML file that contains key-value mappings to become variables for this lookup`)
-	flags.StringArrayVar(&cmdOpts.Variables, `var`, nil,
+	flags.StringArrayVar(&routerConfig.Options.Variables, `var`, nil,
 		`variable as a key:value or key=value where value is a literal expressed in Puppet DSL`)
 	flags.StringVar(&addr, `addr`, ``, `ip address to listen on`)
 	flags.StringVar(&sslKey, `ssl-key`, ``, `ssl private key`)
@@ -67,22 +84,56 @@ func newCommand() *cobra.Command {
 	flags.StringVar(&clientCA, `ca`, ``, `certificate authority to use to verify clients`)
 	flags.BoolVar(&clientCertVerify, `clientCertVerify`, false, `verify client certificate`)
 	flags.IntVar(&port, `port`, 8080, `port number to listen to`)
+	flags.BoolVar(&watch, `watch`, false, `reload when files in the directory of the hiera config file change`)
+	flags.StringVar(&aclPath, `acl`, ``, `path to a YAML file with rules that control what keys each client may look up`)
+	flags.StringArrayVar(&extensionFlags, `trustedExtension`, nil,
+		`client certificate extension to add to trusted.extensions, as name=oid or oid`)
+	flags.StringVar(&environmentsDir, `environments`, ``,
+		`directory with one subdirectory per environment, each with its own hiera config file, served under /env/<name>`)
+	flags.DurationVar(&environmentIdle, `environmentTimeout`, 10*time.Minute,
+		`time after which the session of an unused environment is closed, or 0 to keep it open`)
 	return cmd
 }
 
-var keyPattern = regexp.MustCompile(`^/lookup/(.*)$`)
-
 func startServer(_ *cobra.Command, _ []string) {
+	lookupKeys := []sdk.LookupKey{provider.ConfigLookupKey, provider.Environment}
 	configOptions := map[string]interface{}{
-		provider.LookupKeyFunctions: []sdk.LookupKey{provider.ConfigLookupKey, provider.Environment},
+		provider.LookupKeyFunctions: lookupKeys,
 		api.HieraConfig:             configPath}
 
+	if aclPath != `` {
+		var err error
+		if routerConfig.ACL, err = acl.Load(aclPath); err != nil {
+			panic(err)
+		}
+	}
+
+	for _, ef := range extensionFlags {
+		ext, err := trusted.ParseExtension(ef)
+		if err != nil {
+			panic(err)
+		}
+		routerConfig.Extensions = append(routerConfig.Extensions, ext)
+	}
+
+	metrics.Enable()
 	hiera.DoWithParent(context.Background(), provider.MuxLookupKey, configOptions, func(hs api.Session) {
-		router := CreateRouter(hs)
+		if environmentsDir != `` {
+			envOptions := map[string]interface{}{provider.LookupKeyFunctions: lookupKeys}
+			pool := environments.NewPool(context.Background(), environmentsDir, provider.MuxLookupKey, envOptions, environmentIdle)
+			defer func() {
+				_ = pool.Close()
+			}()
+			routerConfig.Environments = pool
+		}
+		reloadOnHangup(hs)
+		if watch {
+			defer watchAndReload(hs)()
+		}
 
 		server := &http.Server{
 			Addr:    addr + ":" + strconv.Itoa(port),
-			Handler: router,
+			Handler: router.New(hs, &routerConfig),
 		}
 
 		tlsConfig, err := makeTLSconfig()
@@ -102,51 +153,38 @@ func startServer(_ *cobra.Command, _ []string) {
 	})
 }
 
-// CreateRouter creates the http.Handler for the Hiera RESTful service
-func CreateRouter(ctx api.Session) http.Handler {
-	doLookup := func(w http.ResponseWriter, r *http.Request) {
-		ks := keyPattern.FindStringSubmatch(r.URL.Path)
-		if ks == nil {
-			http.NotFound(w, r)
-			return
-		}
-		key := ks[1]
-
-		defer func() {
-			if r := recover(); r != nil {
-				var err error
-				if er, ok := r.(error); ok {
-					err = er
-				} else if es, ok := r.(string); ok {
-					err = errors.New(es)
-				} else {
-					panic(r)
-				}
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-			}
-		}()
-
-		opts := cmdOpts
-		params := r.URL.Query()
-		if dflt, ok := params[`default`]; ok && len(dflt) > 0 {
-			opts.Default = &dflt[0]
-		}
-		opts.Merge = params.Get(`merge`)
-		opts.Type = params.Get(`type`)
-		opts.Variables = append(opts.Variables, params[`var`]...)
-		opts.RenderAs = `json`
-		out := bytes.Buffer{}
-		if hiera.LookupAndRender(ctx, &opts, []string{key}, &out) {
-			w.Header().Set("Content-Type", "application/json")
-			_, _ = w.Write(out.Bytes())
-		} else {
-			http.Error(w, `404 value not found`, http.StatusNotFound)
+// reloadOnHangup starts a go routine that reloads the given session and the sessions of all environments each time
+// the process receives a SIGHUP
+func reloadOnHangup(hs api.Session) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			router.Reload(hs, &routerConfig)
 		}
-	}
+	}()
+}
 
-	router := http.NewServeMux()
-	router.HandleFunc("/lookup/", doLookup)
-	return router
+// watchAndReload starts watching the directory of the hiera config file of the given session and the environments
+// directory, and returns a function that stops the watching
+func watchAndReload(hs api.Session) func() {
+	w, err := hiera.WatchAndReload(hs, watchDelay)
+	if err != nil {
+		panic(err)
+	}
+	pool := routerConfig.Environments
+	if pool == nil {
+		return func() { _ = w.Close() }
+	}
+	ew, err := hiera.Watch(watchDelay, pool.Reload, pool.Dir())
+	if err != nil {
+		_ = w.Close()
+		panic(err)
+	}
+	return func() {
+		_ = w.Close()
+		_ = ew.Close()
+	}
 }
 
 func loadCertPool(pemFile string) (*x509.CertPool, error) {
@@ -0,0 +1,365 @@
+// Package router contains the http.Handler that serves the REST API of the Hiera server
+package router
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+
+	"github.com/lyraproj/dgo/dgo"
+	"github.com/lyraproj/dgo/util"
+	"github.com/lyraproj/hiera/api"
+	"github.com/lyraproj/hiera/hiera"
+	"github.com/lyraproj/hiera/hieraserver/acl"
+	"github.com/lyraproj/hiera/hieraserver/environments"
+	"github.com/lyraproj/hiera/hieraserver/trusted"
+	"github.com/lyraproj/hiera/metrics"
+)
+
+// Config contains the settings of the router. The zero value serves the session given to New without access
+// control and without environments.
+type Config struct {
+	// Options are the options used for all lookups. Typically the variables given when the server was started.
+	Options hiera.CommandOptions
+
+	// ACL controls what keys and endpoints each client may access. All clients may access everything when it is nil.
+	ACL *acl.ACL
+
+	// Extensions are the client certificate extensions that are included in the trusted hash
+	Extensions []trusted.Extension
+
+	// Environments is the pool of sessions that serves /env/<name>. No environments are served when it is nil.
+	Environments *environments.Pool
+}
+
+var keyPattern = regexp.MustCompile(`^/lookup/(.*)$`)
+var explainPattern = regexp.MustCompile(`^/explain/(.*)$`)
+var envPattern = regexp.MustCompile(`^/env/([^/]+)(/.*)$`)
+
+type router struct {
+	Config
+	session api.Session
+}
+
+// New creates the http.Handler for the Hiera RESTful service that performs lookups using the given session
+func New(s api.Session, cfg *Config) http.Handler {
+	rt := &router{session: s}
+	if cfg != nil {
+		rt.Config = *cfg
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/lookup/", func(w http.ResponseWriter, r *http.Request) { rt.lookup(s, w, r) })
+	mux.HandleFunc("/lookup", func(w http.ResponseWriter, r *http.Request) { rt.batch(s, w, r) })
+	mux.HandleFunc("/explain/", func(w http.ResponseWriter, r *http.Request) { rt.explain(s, w, r) })
+	mux.HandleFunc("/env/", rt.environment)
+	mux.HandleFunc("/reload", rt.reload)
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = w.Write([]byte("ok\n"))
+	})
+	mux.HandleFunc("/readyz", rt.ready)
+	metricsHandler := metrics.Handler()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		if !rt.authorizeEndpoint(w, r, acl.MetricsEndpoint) {
+			return
+		}
+		metricsHandler.ServeHTTP(w, r)
+	})
+	return mux
+}
+
+// Reload reloads the given session and the sessions of all environments of the given configuration
+func Reload(s api.Session, cfg *Config) {
+	s.Reload()
+	if cfg != nil && cfg.Environments != nil {
+		cfg.Environments.Reload()
+	}
+}
+
+func (rt *router) lookup(s api.Session, w http.ResponseWriter, r *http.Request) {
+	ks := keyPattern.FindStringSubmatch(r.URL.Path)
+	if ks == nil {
+		http.NotFound(w, r)
+		return
+	}
+	key := ks[1]
+
+	defer recoverError(w)
+
+	opts, err := rt.lookupOptions(s, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !rt.authorize(w, r, key, opts.Merge) {
+		return
+	}
+	v := hiera.LookupValue(s, &opts, []string{key})
+	if v == nil {
+		http.Error(w, `404 value not found`, http.StatusNotFound)
+		return
+	}
+	if !rt.authorizeValue(w, r, key, v) {
+		return
+	}
+	writeJSON(s, w, v)
+}
+
+func (rt *router) explain(s api.Session, w http.ResponseWriter, r *http.Request) {
+	ks := explainPattern.FindStringSubmatch(r.URL.Path)
+	if ks == nil {
+		http.NotFound(w, r)
+		return
+	}
+	key := ks[1]
+
+	defer recoverError(w)
+
+	opts, err := rt.lookupOptions(s, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if opts.ExplainOptions, err = boolParam(r.URL.Query(), `explain_options`); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if opts.Provenance {
+		http.Error(w, `the provenance parameter cannot be used with explain`, http.StatusBadRequest)
+		return
+	}
+	if !rt.authorize(w, r, key, opts.Merge) {
+		return
+	}
+	result := hiera.Explain(s, &opts, []string{key})
+	if v := result.Get(`value`); v != nil && !rt.authorizeValue(w, r, key, v) {
+		return
+	}
+	writeJSON(s, w, result)
+}
+
+func (rt *router) batch(s api.Session, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set(`Allow`, http.MethodPost)
+		http.Error(w, `405 method not allowed`, http.StatusMethodNotAllowed)
+		return
+	}
+	bs, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req, err := hiera.ParseBatchRequest(bs)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	for _, key := range req.Keys {
+		if !rt.authorize(w, r, key, req.Merge) {
+			return
+		}
+	}
+	var result dgo.Map
+	opts := rt.Options
+	opts.Trusted = trusted.FromRequest(r, rt.Extensions)
+	if err = util.Catch(func() { result = hiera.LookupBatch(s, &opts, req) }); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	for _, key := range req.Keys {
+		if v := result.Get(key).(dgo.Map).Get(`value`); v != nil && !rt.authorizeValue(w, r, key, v) {
+			return
+		}
+	}
+	writeJSON(s, w, result)
+}
+
+// environment serves the lookup, batch lookup, and explain endpoints of the environment that is appointed by the
+// /env/<name> prefix of the request path using the session of that environment
+func (rt *router) environment(w http.ResponseWriter, r *http.Request) {
+	ps := envPattern.FindStringSubmatch(r.URL.Path)
+	if rt.Environments == nil || ps == nil {
+		http.NotFound(w, r)
+		return
+	}
+	er := r.Clone(r.Context())
+	er.URL.Path = ps[2]
+	er.URL.RawPath = ``
+
+	err := rt.Environments.Do(ps[1], func(es api.Session) {
+		switch {
+		case keyPattern.MatchString(er.URL.Path):
+			rt.lookup(es, w, er)
+		case er.URL.Path == `/lookup`:
+			rt.batch(es, w, er)
+		case explainPattern.MatchString(er.URL.Path):
+			rt.explain(es, w, er)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+	}
+}
+
+func (rt *router) reload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set(`Allow`, http.MethodPost)
+		http.Error(w, `405 method not allowed`, http.StatusMethodNotAllowed)
+		return
+	}
+	if !rt.authorizeEndpoint(w, r, acl.ReloadEndpoint) {
+		return
+	}
+	Reload(rt.session, &rt.Config)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write([]byte("reloaded\n"))
+}
+
+// ready responds with 503 Service Unavailable unless the hiera.yaml of the session of the router and the hiera.yaml of
+// every environment in its pool can be parsed and all functions used in their hierarchies can be loaded
+func (rt *router) ready(w http.ResponseWriter, r *http.Request) {
+	if err := rt.checkConfig(); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	_, _ = w.Write([]byte("ok\n"))
+}
+
+// checkConfig calls hiera.CheckConfig with the session of the router and hiera.CheckConfigFile with the hiera.yaml of
+// each environment in the environments directory. The environments are checked using the session of the router so
+// that a probe neither creates environment sessions nor keeps idle ones from being closed.
+func (rt *router) checkConfig() error {
+	if err := hiera.CheckConfig(rt.session); err != nil {
+		return err
+	}
+	if rt.Environments == nil {
+		return nil
+	}
+	names, err := rt.Environments.Available()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		path, err := rt.Environments.ConfigFile(name)
+		if _, ok := err.(*environments.NotFoundError); ok {
+			continue // removed since it was listed
+		}
+		if err == nil {
+			err = hiera.CheckConfigFile(rt.session, path)
+		}
+		if err != nil {
+			return fmt.Errorf(`environment '%s': %s`, name, err.Error())
+		}
+	}
+	return nil
+}
+
+func writeJSON(s api.Session, w http.ResponseWriter, v dgo.Value) {
+	out := bytes.Buffer{}
+	hiera.Render(s, hiera.JSON, v, &out)
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(out.Bytes())
+}
+
+// authorize responds with 403 Forbidden and returns false unless the access list grants the client of the given
+// request access to the given key using the given merge strategy. It always returns true when no access list is used.
+func (rt *router) authorize(w http.ResponseWriter, r *http.Request, key, merge string) bool {
+	if rt.ACL != nil {
+		if err := rt.ACL.Authorize(r, key, merge); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return false
+		}
+	}
+	return true
+}
+
+// authorizeValue responds with 403 Forbidden and returns false if the given value of the given key contains a
+// Sensitive value that the access list doesn't grant the client of the given request access to. It always returns
+// true when no access list is used.
+func (rt *router) authorizeValue(w http.ResponseWriter, r *http.Request, key string, v dgo.Value) bool {
+	if rt.ACL != nil && acl.ContainsSensitive(v) {
+		if err := rt.ACL.AuthorizeSensitive(r, key); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return false
+		}
+	}
+	return true
+}
+
+// authorizeEndpoint responds with 403 Forbidden and returns false unless the access list grants the client of the
+// given request access to the given endpoint. It always returns true when no access list is used.
+func (rt *router) authorizeEndpoint(w http.ResponseWriter, r *http.Request, endpoint string) bool {
+	if rt.ACL != nil {
+		if err := rt.ACL.AuthorizeEndpoint(r, endpoint); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return false
+		}
+	}
+	return true
+}
+
+// recoverError recovers a panic with an error or a string and responds with a 500 Internal Server Error
+func recoverError(w http.ResponseWriter) {
+	if r := recover(); r != nil {
+		var err error
+		if er, ok := r.(error); ok {
+			err = er
+		} else if es, ok := r.(string); ok {
+			err = errors.New(es)
+		} else {
+			panic(r)
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// lookupOptions creates the options for a lookup from the query parameters of a request. The options of the router
+// are included, and so is the trusted hash built from the client certificate of the request. The type is parsed using
+// the dialect of the given session.
+func (rt *router) lookupOptions(s api.Session, r *http.Request) (hiera.CommandOptions, error) {
+	params := r.URL.Query()
+	opts := rt.Options
+	opts.Trusted = trusted.FromRequest(r, rt.Extensions)
+	if dflt, ok := params[`default`]; ok && len(dflt) > 0 {
+		opts.Default = &dflt[0]
+	}
+	opts.Merge = params.Get(`merge`)
+	opts.KnockoutPrefix = params.Get(`knockout_prefix`)
+	var err error
+	if opts.SortMergedArrays, err = boolParam(params, `sort_merged_arrays`); err != nil {
+		return opts, err
+	}
+	if opts.MergeHashArrays, err = boolParam(params, `merge_hash_arrays`); err != nil {
+		return opts, err
+	}
+	if opts.Provenance, err = boolParam(params, `provenance`); err != nil {
+		return opts, err
+	}
+	opts.Type = params.Get(`type`)
+	opts.Variables = append(opts.Variables[:len(opts.Variables):len(opts.Variables)], params[`var`]...)
+	if err = hiera.ValidateType(s, &opts); err != nil {
+		return opts, err
+	}
+	return opts, hiera.ValidateMergeOptions(&opts)
+}
+
+// boolParam returns the boolean value of the given query parameter, or false if the parameter is not present.
+func boolParam(params url.Values, name string) (bool, error) {
+	v := params.Get(name)
+	if v == `` {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf(`invalid value '%s' for parameter '%s'`, v, name)
+	}
+	return b, nil
+}
@@ -0,0 +1,106 @@
+// Package trusted creates the "trusted" hash that the Hiera REST server adds to the scope of each lookup. The hash is
+// built from the verified client certificate of the request so that hierarchies can use the identity of the client
+// rather than variables that the client supplies.
+package trusted
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/lyraproj/dgo/dgo"
+	"github.com/lyraproj/dgo/vf"
+)
+
+// An Extension is a certificate extension that is included in the "extensions" hash of the trusted hash
+type Extension struct {
+	// Name is the key of the extension in the "extensions" hash
+	Name string
+
+	// OID is the object identifier of the extension
+	OID asn1.ObjectIdentifier
+}
+
+// ParseExtension parses an extension from a string in the form "<name>=<oid>" or "<oid>". The dotted OID is used as
+// the name when no name is given.
+func ParseExtension(s string) (Extension, error) {
+	name, oidStr := s, s
+	if ix := strings.IndexByte(s, '='); ix >= 0 {
+		name, oidStr = strings.TrimSpace(s[:ix]), strings.TrimSpace(s[ix+1:])
+	}
+	var oid asn1.ObjectIdentifier
+	for _, p := range strings.Split(oidStr, `.`) {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return Extension{}, fmt.Errorf(`invalid OID '%s' in extension '%s'`, oidStr, s)
+		}
+		oid = append(oid, n)
+	}
+	if len(oid) < 2 || name == `` {
+		return Extension{}, fmt.Errorf(`invalid extension '%s'`, s)
+	}
+	return Extension{Name: name, OID: oid}, nil
+}
+
+// FromRequest returns the trusted hash of the given request. When the request has a verified client certificate, the
+// hash contains "authenticated" with the value "remote", the common name of the certificate as "certname", the parts
+// of the certname before and after the first dot as "hostname" and "domain", the DNS names and IP addresses of the
+// subject alternative names as "dns_alt_names" and "ip_alt_names", and the values of the given extensions that are
+// present in the certificate as "extensions". Without a verified certificate, the hash only contains "authenticated"
+// with the value false and an empty "extensions" hash.
+func FromRequest(r *http.Request, extensions []Extension) dgo.Map {
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+		return FromCertificate(r.TLS.VerifiedChains[0][0], extensions)
+	}
+	return vf.Map(`authenticated`, false, `extensions`, vf.Map())
+}
+
+// FromCertificate returns the trusted hash for the given verified certificate. See FromRequest.
+func FromCertificate(cert *x509.Certificate, extensions []Extension) dgo.Map {
+	certname := cert.Subject.CommonName
+	hostname, domain := certname, ``
+	if ix := strings.IndexByte(certname, '.'); ix >= 0 {
+		hostname, domain = certname[:ix], certname[ix+1:]
+	}
+
+	ips := vf.MutableValues()
+	for _, ip := range cert.IPAddresses {
+		ips.Add(ip.String())
+	}
+
+	exts := vf.MapWithCapacity(len(extensions))
+	for _, ext := range extensions {
+		for i := range cert.Extensions {
+			if ce := &cert.Extensions[i]; ce.Id.Equal(ext.OID) {
+				exts.Put(ext.Name, extensionValue(ce.Value))
+				break
+			}
+		}
+	}
+
+	return vf.Map(
+		`authenticated`, `remote`,
+		`certname`, certname,
+		`hostname`, hostname,
+		`domain`, domain,
+		`dns_alt_names`, vf.Strings(cert.DNSNames...),
+		`ip_alt_names`, ips,
+		`extensions`, exts)
+}
+
+// extensionValue returns the string contained in the given DER encoded extension value. Values that aren't ASN.1
+// strings are returned hex encoded.
+func extensionValue(der []byte) string {
+	var rv asn1.RawValue
+	if rest, err := asn1.Unmarshal(der, &rv); err == nil && len(rest) == 0 && rv.Class == asn1.ClassUniversal {
+		switch rv.Tag {
+		case asn1.TagUTF8String, asn1.TagPrintableString, asn1.TagIA5String, asn1.TagT61String:
+			return string(rv.Bytes)
+		}
+	}
+	return hex.EncodeToString(der)
+}
@@ -12,6 +12,7 @@ import (
 type dataDigProvider struct {
 	hierarchyEntry api.Entry
 	providerFunc   hiera.DataDig
+	unresolved     bool
 }
 
 func (dh *dataDigProvider) Hierarchy() api.Entry {
@@ -21,7 +22,7 @@ func (dh *dataDigProvider) Hierarchy() api.Entry {
 func (dh *dataDigProvider) LookupKey(key api.Key, ic api.Invocation, location api.Location) dgo.Value {
 	opts := dh.hierarchyEntry.Options()
 	if location != nil {
-		opts = optionsWithLocation(opts, location.Resolved())
+		opts = optionsWithLocation(opts, location)
 	}
 	value := dh.providerFunction(ic)(ic.ServerContext(opts), vf.Values(key.Parts()...))
 	if value != nil {
@@ -47,10 +48,16 @@ func (dh *dataDigProvider) loadFunction(ic api.Invocation) (pf hiera.DataDig) {
 			return f.(dgo.Function).Call(vf.Values(ic, key))[0]
 		}
 	}
+	dh.unresolved = true
 	ic.ReportText(func() string { return fmt.Sprintf(`unresolved function '%s'`, he.Function().Name()) })
 	return func(hiera.ProviderContext, dgo.Array) dgo.Value { return nil }
 }
 
+func (dh *dataDigProvider) functionResolved(ic api.Invocation) bool {
+	dh.providerFunction(ic)
+	return !dh.unresolved
+}
+
 func (dh *dataDigProvider) FullName() string {
 	return fmt.Sprintf(`data_dig function '%s'`, dh.hierarchyEntry.Function().Name())
 }
@@ -7,6 +7,7 @@ import (
 	"github.com/lyraproj/dgo/dgo"
 	"github.com/lyraproj/dgo/vf"
 	"github.com/lyraproj/hiera/api"
+	"github.com/lyraproj/hiera/metrics"
 	"github.com/lyraproj/hiera/provider"
 	"github.com/lyraproj/hierasdk/hiera"
 )
@@ -14,7 +15,10 @@ import (
 type dataHashProvider struct {
 	hierarchyEntry api.Entry
 	providerFunc   hiera.DataHash
+	unresolved     bool
+	positionFunc   func(path string) map[string]*api.Position
 	hashes         dgo.Map
+	positions      map[string]map[string]*api.Position
 	hashesLock     sync.RWMutex
 }
 
@@ -25,7 +29,11 @@ func (dh *dataHashProvider) Hierarchy() api.Entry {
 func (dh *dataHashProvider) LookupKey(key api.Key, ic api.Invocation, location api.Location) dgo.Value {
 	root := key.Root()
 	if value := dh.dataValue(ic, location, root); value != nil {
-		ic.ReportFound(root, value)
+		if pos := dh.Position(location, root); pos != nil {
+			ic.ReportFoundAt(root, value, pos.String())
+		} else {
+			ic.ReportFound(root, value)
+		}
 		return value
 	}
 	ic.ReportNotFound(root)
@@ -37,9 +45,30 @@ func (dh *dataHashProvider) dataValue(ic api.Invocation, location api.Location,
 	if value == nil {
 		return nil
 	}
+	if pos := dh.Position(location, root); pos != nil {
+		defer func() {
+			if r := recover(); r != nil {
+				if err, ok := r.(error); ok {
+					panic(fmt.Errorf(`%s: %s`, pos, err.Error()))
+				}
+				panic(r)
+			}
+		}()
+	}
 	return ic.Interpolate(value, true)
 }
 
+// Position returns the position of the given key path in the data found at the given location, or nil
+// if positions are not retained.
+func (dh *dataHashProvider) Position(location api.Location, path string) *api.Position {
+	if location == nil {
+		return nil
+	}
+	dh.hashesLock.RLock()
+	defer dh.hashesLock.RUnlock()
+	return dh.positions[location.Resolved()][path]
+}
+
 func (dh *dataHashProvider) providerFunction(ic api.Invocation) (pf hiera.DataHash) {
 	if dh.providerFunc == nil {
 		dh.providerFunc = dh.loadFunction(ic)
@@ -49,11 +78,33 @@ func (dh *dataHashProvider) providerFunction(ic api.Invocation) (pf hiera.DataHa
 
 func (dh *dataHashProvider) loadFunction(ic api.Invocation) hiera.DataHash {
 	n := dh.hierarchyEntry.Function().Name()
+	positions := false
+	if pv, ok := ic.SessionOptions().Get(api.HieraPositions).(dgo.Boolean); ok {
+		positions = pv.GoBool()
+	}
 	switch n {
 	case `yaml_data`:
+		if positions {
+			dh.positionFunc = provider.YamlPositions
+		}
 		return provider.YamlData
 	case `json_data`:
+		if positions {
+			dh.positionFunc = provider.JSONPositions
+		}
 		return provider.JSONData
+	case `sops_data`:
+		return provider.SopsData
+	case `toml_data`:
+		return provider.TomlData
+	case `hcl_data`:
+		return provider.HclData
+	case `ini_data`:
+		return provider.IniData
+	case `dotenv_data`:
+		return provider.DotenvData
+	case `http_data_hash`:
+		return provider.HTTPDataHash
 	}
 
 	if fn, ok := ic.LoadFunction(dh.hierarchyEntry); ok {
@@ -67,6 +118,7 @@ func (dh *dataHashProvider) loadFunction(ic api.Invocation) hiera.DataHash {
 		}
 	}
 
+	dh.unresolved = true
 	ic.ReportText(func() string { return fmt.Sprintf(`unresolved function '%s'`, n) })
 	return func(pc hiera.ProviderContext) dgo.Map {
 		return vf.Map()
@@ -78,13 +130,14 @@ func (dh *dataHashProvider) dataHash(ic api.Invocation, location api.Location) (
 	opts := dh.hierarchyEntry.Options()
 	if location != nil {
 		key = location.Resolved()
-		opts = optionsWithLocation(opts, key)
+		opts = optionsWithLocation(opts, location)
 	}
 
 	var ok bool
 	dh.hashesLock.RLock()
 	hash, ok = dh.hashes.Get(key).(dgo.Map)
 	dh.hashesLock.RUnlock()
+	metrics.ObserveCache(metrics.DataHashCache, ok)
 	if ok {
 		return
 	}
@@ -97,9 +150,20 @@ func (dh *dataHashProvider) dataHash(ic api.Invocation, location api.Location) (
 	}
 	hash = dh.providerFunction(ic)(ic.ServerContext(opts))
 	dh.hashes.Put(key, hash)
+	if dh.positionFunc != nil && location != nil {
+		if dh.positions == nil {
+			dh.positions = make(map[string]map[string]*api.Position)
+		}
+		dh.positions[key] = dh.positionFunc(key)
+	}
 	return
 }
 
+func (dh *dataHashProvider) functionResolved(ic api.Invocation) bool {
+	dh.providerFunction(ic)
+	return !dh.unresolved
+}
+
 func (dh *dataHashProvider) FullName() string {
 	return fmt.Sprintf(`data_hash function '%s'`, dh.hierarchyEntry.Function().Name())
 }
@@ -110,6 +174,11 @@ func NewDataHashProvider(he api.Entry) api.DataProvider {
 	return &dataHashProvider{hierarchyEntry: he, hashes: vf.MapWithCapacity(len(ls))}
 }
 
-func optionsWithLocation(options dgo.Map, loc string) dgo.Map {
-	return options.Merge(vf.Map(`path`, loc))
+// optionsWithLocation returns the given options with the resolved location added as the "path" option. A uri
+// location is also added as the "uri" option.
+func optionsWithLocation(options dgo.Map, location api.Location) dgo.Map {
+	if location.Kind() == api.LcURI {
+		return options.Merge(vf.Map(`path`, location.Resolved(), `uri`, location.Resolved()))
+	}
+	return options.Merge(vf.Map(`path`, location.Resolved()))
 }
@@ -0,0 +1,18 @@
+package internal
+
+import "github.com/lyraproj/hiera/api"
+
+// functionResolver is implemented by the data providers of this package
+type functionResolver interface {
+	functionResolved(ic api.Invocation) bool
+}
+
+// FunctionResolved loads the function of the given data provider unless it is already loaded and returns false if
+// the function cannot be found. Loading the function of a plugin starts the plugin, so a plugin that fails to start
+// results in a panic.
+func FunctionResolved(ic api.Invocation, dp api.DataProvider) bool {
+	if fr, ok := dp.(functionResolver); ok {
+		return fr.functionResolved(ic)
+	}
+	return true
+}
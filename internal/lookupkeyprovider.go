@@ -13,6 +13,7 @@ import (
 type lookupKeyProvider struct {
 	hierarchyEntry api.Entry
 	providerFunc   hiera.LookupKey
+	unresolved     bool
 }
 
 func (dh *lookupKeyProvider) Hierarchy() api.Entry {
@@ -23,7 +24,7 @@ func (dh *lookupKeyProvider) LookupKey(key api.Key, ic api.Invocation, location
 	root := key.Root()
 	opts := dh.hierarchyEntry.Options()
 	if location != nil {
-		opts = optionsWithLocation(opts, location.Resolved())
+		opts = optionsWithLocation(opts, location)
 	}
 	value := dh.providerFunction(ic)(ic.ServerContext(opts), root)
 	if value != nil {
@@ -44,8 +45,16 @@ func (dh *lookupKeyProvider) providerFunction(ic api.Invocation) (pf hiera.Looku
 func (dh *lookupKeyProvider) loadFunction(ic api.Invocation) (pf hiera.LookupKey) {
 	n := dh.hierarchyEntry.Function().Name()
 	switch n {
+	case `directory_data`:
+		return provider.DirectoryData
 	case `environment`:
 		return provider.Environment
+	case `eyaml_lookup_key`:
+		return provider.EyamlLookupKey
+	case `hiera_rest`:
+		return provider.HieraRestLookupKey
+	case `http_lookup_key`:
+		return provider.HTTPLookupKey
 	case `scope`:
 		return provider.ScopeLookupKey
 	}
@@ -54,10 +63,16 @@ func (dh *lookupKeyProvider) loadFunction(ic api.Invocation) (pf hiera.LookupKey
 			return f.Call(vf.MutableValues(pc, key))[0]
 		}
 	}
+	dh.unresolved = true
 	ic.ReportText(func() string { return fmt.Sprintf(`unresolved function '%s'`, n) })
 	return func(pc hiera.ProviderContext, key string) dgo.Value { return nil }
 }
 
+func (dh *lookupKeyProvider) functionResolved(ic api.Invocation) bool {
+	dh.providerFunction(ic)
+	return !dh.unresolved
+}
+
 func (dh *lookupKeyProvider) FullName() string {
 	return fmt.Sprintf(`lookup_key function '%s'`, dh.hierarchyEntry.Function().Name())
 }
@@ -107,6 +107,14 @@ func TestLookup_fact_directly(t *testing.T) {
 	})
 }
 
+func TestLookup_fact_directly_int(t *testing.T) {
+	inTestdata(func() {
+		result, err := cli.ExecuteLookup(`--var`, `the_fact=5`, `--config`, `fact_directly_hiera.yaml`, `--render-as`, `json`, `the_fact`)
+		require.NoError(t, err)
+		require.Equal(t, "5\n", string(result))
+	})
+}
+
 func TestLookup_nullentry(t *testing.T) {
 	inTestdata(func() {
 		result, err := cli.ExecuteLookup(`nullentry`)
@@ -402,6 +410,103 @@ Searching for "hash"
 	})
 }
 
+func TestLookup_deepKnockOutPrefix(t *testing.T) {
+	inTestdata(func() {
+		result, err := cli.ExecuteLookup(`--facts`, `facts.yaml`, `--merge`, `deep`, `--knock-out-prefix=--`,
+			`--render-as`, `json`, `packages`)
+		require.NoError(t, err)
+		require.Equal(t, "[\"zsh\",\"ksh\",\"git\",\"bash\"]\n", string(result))
+	})
+}
+
+func TestLookup_deepSortMergedArrays(t *testing.T) {
+	inTestdata(func() {
+		result, err := cli.ExecuteLookup(`--facts`, `facts.yaml`, `--merge`, `deep`, `--knock-out-prefix=--`,
+			`--sort-merged-arrays`, `--render-as`, `json`, `packages`)
+		require.NoError(t, err)
+		require.Equal(t, "[\"bash\",\"git\",\"ksh\",\"zsh\"]\n", string(result))
+	})
+}
+
+func TestLookup_deepMergeHashArrays(t *testing.T) {
+	inTestdata(func() {
+		result, err := cli.ExecuteLookup(`--facts`, `facts.yaml`, `--merge`, `deep`, `--merge-hash-arrays`,
+			`--render-as`, `json`, `servers`)
+		require.NoError(t, err)
+		require.Equal(t, "[{\"name\":\"one\",\"port\":8080,\"host\":\"a.example.com\"}]\n", string(result))
+	})
+}
+
+func TestLookup_deepOptionsExplain(t *testing.T) {
+	inTestdata(func() {
+		result, err := cli.ExecuteLookup(`--facts`, `facts.yaml`, `--merge`, `deep`, `--sort-merged-arrays`, `--explain`, `packages`)
+		require.NoError(t, err)
+		require.Regexp(t, `Using merge options from CLI option
+  Merge strategy "deep merge strategy"
+    Options: \{
+      "sort_merged_arrays": true
+    \}`, string(result))
+	})
+}
+
+func TestLookup_deepOptionsRequireDeep(t *testing.T) {
+	inTestdata(func() {
+		_, err := cli.ExecuteLookup(`--facts`, `facts.yaml`, `--merge`, `unique`, `--sort-merged-arrays`, `packages`)
+		if assert.Error(t, err) {
+			require.Regexp(t, `only valid with merge 'deep'`, err.Error())
+		}
+	})
+}
+
+func TestLookup_provenance(t *testing.T) {
+	inTestdata(func() {
+		result, err := cli.ExecuteLookup(`--facts`, `facts.yaml`, `--provenance`, `--render-as`, `json`, `hash`)
+		require.NoError(t, err)
+		require.Regexp(t, `\A\{"value":\{"one":1,"two":"two","three":\{"a":"A","c":"C","b":"B"\}\},"provenance":\{`+
+			`"one":\{"level":"Common","provider":"data_hash function 'yaml_data'","location":"[^"]*common\.yaml"\},`+
+			`"two":\{"level":"Common",[^}]*\},`+
+			`"three":\{"a":\{"level":"Common",[^}]*\},"c":\{"level":"Common",[^}]*\},`+
+			`"b":\{"level":"Stuff","provider":"data_hash function 'yaml_data'","location":"[^"]*named_by_fact\.yaml"\}\}\}\}`,
+			string(result))
+	})
+}
+
+func TestLookup_provenanceMergedArray(t *testing.T) {
+	inTestdata(func() {
+		result, err := cli.ExecuteLookup(`--facts`, `facts.yaml`, `--merge`, `unique`, `--provenance`, `--render-as`, `json`, `packages`)
+		require.NoError(t, err)
+		require.Regexp(t, `\A\{"value":\["zsh","--vim","ksh","vim","git","bash"\],"provenance":\[`+
+			`\{"level":"Common",[^}]*\},\{"level":"Common",[^}]*\},\{"level":"Common",[^}]*\},`+
+			`\{"level":"Stuff",[^}]*\},\{"level":"Stuff",[^}]*\},\{"level":"Stuff",[^}]*\}\]\}`, string(result))
+	})
+}
+
+func TestLookup_provenanceNotWithAll(t *testing.T) {
+	inTestdata(func() {
+		_, err := cli.ExecuteLookup(`--facts`, `facts.yaml`, `--provenance`, `--all`, `hash`)
+		if assert.Error(t, err) {
+			require.Regexp(t, `provenance option cannot be combined`, err.Error())
+		}
+	})
+}
+
+func TestLookup_explainPositions(t *testing.T) {
+	inTestdata(func() {
+		result, err := cli.ExecuteLookup(`--facts`, `facts.yaml`, `--explain`, `--positions`, `simple`)
+		require.NoError(t, err)
+		require.Regexp(t, `Found key: "simple" value: "value" at .*common\.yaml:38:1`, string(result))
+	})
+}
+
+func TestLookup_provenanceNotWithExplain(t *testing.T) {
+	inTestdata(func() {
+		_, err := cli.ExecuteLookup(`--facts`, `facts.yaml`, `--provenance`, `--explain`, `hash`)
+		if assert.Error(t, err) {
+			require.Regexp(t, `provenance option cannot be combined with the explain options`, err.Error())
+		}
+	})
+}
+
 func TestLookupKey_plugin(t *testing.T) {
 	ensureTestPlugin(t)
 	inTestdata(func() {
@@ -0,0 +1,54 @@
+package merge
+
+import (
+	"fmt"
+
+	"github.com/lyraproj/dgo/dgo"
+	"github.com/lyraproj/hiera/api"
+)
+
+// An arrayPolicy controls how two arrays are merged by the deep and unique merge strategies. The policy is
+// declared using the array_merge option.
+type arrayPolicy string
+
+const (
+	// unionArrays creates a union of the unique elements of both arrays. This is the default.
+	unionArrays = arrayPolicy(`union`)
+
+	// appendArrays appends the elements of the higher priority array to the lower priority array and retains
+	// duplicates.
+	appendArrays = arrayPolicy(`append`)
+
+	// prependArrays prepends the elements of the higher priority array to the lower priority array and retains
+	// duplicates.
+	prependArrays = arrayPolicy(`prepend`)
+
+	// replaceArrays lets the higher priority array replace the lower priority array.
+	replaceArrays = arrayPolicy(`replace`)
+)
+
+func parseArrayPolicy(v dgo.Value) arrayPolicy {
+	if s, ok := v.(dgo.String); ok {
+		switch p := arrayPolicy(s.GoString()); p {
+		case unionArrays, appendArrays, prependArrays, replaceArrays:
+			return p
+		}
+	}
+	panic(fmt.Errorf(`invalid array_merge policy '%s'. Expected one of 'append', 'prepend', 'replace', or 'union'`, v))
+}
+
+// combine combines the higher priority array a with the lower priority array b. The pa and pb slices hold the
+// provenances of the elements of a and b, and the returned slice holds the provenances of the elements of the result.
+func (p arrayPolicy) combine(a, b dgo.Array, pa, pb []*api.Provenance) (dgo.Array, []*api.Provenance) {
+	switch p {
+	case appendArrays:
+		return b.WithAll(a), concat(pb, pa)
+	case prependArrays:
+		return a.WithAll(b), concat(pa, pb)
+	case replaceArrays:
+		return a, pa
+	default:
+		ab := a.WithAll(b)
+		return ab.Unique(), uniqueProvenance(ab, concat(pa, pb))
+	}
+}
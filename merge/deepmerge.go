@@ -1,11 +1,21 @@
 package merge
 
 import (
+	"strings"
+
 	"github.com/lyraproj/dgo/dgo"
 	"github.com/lyraproj/dgo/vf"
 	"github.com/lyraproj/hiera/api"
 )
 
+type deepOptions struct {
+	knockoutPrefix   string
+	sortMergedArrays bool
+	mergeHashArrays  bool
+	arrayMerge       arrayPolicy
+	arrayMergePaths  map[string]arrayPolicy
+}
+
 // Deep will merge the values 'a' and 'b' if both values are hashes or both values are
 // arrays. When this is not the case, no merge takes place and the 'a' argument is returned.
 // The second bool return value true if a merge took place and false when the first argument
@@ -13,49 +23,279 @@ import (
 //
 // When both values are hashes, Deep is called recursively entries with identical keys.
 // When both values are arrays, the merge creates a union of the unique elements from the two arrays.
-// No recursive merge takes place for the array elements.
+// No recursive merge takes place for the array elements unless the merge_hash_arrays option is set.
+//
+// The following options are recognized:
+//
+// knockout_prefix - A string prefix that, when found on a hash key or an array element in 'a', will cause the
+// corresponding key or element to be removed from 'b'. A hash entry in 'a' whose value is equal to the
+// prefix will also cause the key to be removed. The prefixed keys and elements are never included in the result.
+//
+// sort_merged_arrays - Sort all arrays that are merged together.
+//
+// merge_hash_arrays - Deep merge arrays where all elements are hashes by merging the hashes at the same index.
+//
+// array_merge - Controls how arrays are merged. The value is one of "union" (the default), "append", "prepend", or
+// "replace", or a hash that maps dotted key paths inside the merged hashes to such a value. The append and prepend
+// policies retain duplicates, and the replace policy lets the array in 'a' replace the array in 'b'. The
+// merge_hash_arrays option is only used for arrays that use the union policy.
 func Deep(a, b dgo.Value, opi interface{}) (dgo.Value, bool) {
 	var options dgo.Map
 	if opi != nil {
-		options = api.ToMap(`deep merge options`, options)
+		options = api.ToMap(`deep merge options`, opi)
+	}
+	v, _, merged := deep(a, b, nil, nil, newDeepOptions(options), ``)
+	return v, merged
+}
+
+func newDeepOptions(options dgo.Map) *deepOptions {
+	o := &deepOptions{arrayMerge: unionArrays}
+	if options != nil {
+		if kp, ok := options.Get(`knockout_prefix`).(dgo.String); ok {
+			o.knockoutPrefix = kp.GoString()
+		}
+		if sa, ok := options.Get(`sort_merged_arrays`).(dgo.Boolean); ok {
+			o.sortMergedArrays = sa.GoBool()
+		}
+		if ha, ok := options.Get(`merge_hash_arrays`).(dgo.Boolean); ok {
+			o.mergeHashArrays = ha.GoBool()
+		}
+		switch am := options.Get(`array_merge`).(type) {
+		case nil:
+		case dgo.Map:
+			o.arrayMergePaths = make(map[string]arrayPolicy, am.Len())
+			am.EachEntry(func(e dgo.MapEntry) {
+				o.arrayMergePaths[e.Key().String()] = parseArrayPolicy(e.Value())
+			})
+		default:
+			o.arrayMerge = parseArrayPolicy(am)
+		}
 	}
-	return deep(a, b, options)
+	return o
 }
 
-func deep(a, b dgo.Value, options dgo.Map) (dgo.Value, bool) {
+// arrayPolicy returns the policy to use when merging arrays found at the given path
+func (o *deepOptions) arrayPolicy(path string) arrayPolicy {
+	if p, ok := o.arrayMergePaths[path]; ok {
+		return p
+	}
+	return o.arrayMerge
+}
+
+func deep(a, b dgo.Value, pa, pb *api.Provenance, o *deepOptions, path string) (dgo.Value, *api.Provenance, bool) {
 	switch a := a.(type) {
 	case dgo.Map:
 		if hb, ok := b.(dgo.Map); ok {
-			es := vf.MapWithCapacity(a.Len() + hb.Len())
-			a.EachEntry(func(e dgo.MapEntry) {
-				if bv := hb.Get(e.Key()); bv != nil {
-					if m, mh := deep(e.Value(), bv, options); mh {
-						es.Put(e.Key(), m)
-						return
-					}
-				}
-				es.Put(e.Key(), e.Value())
-			})
-			hb.EachEntry(func(e dgo.MapEntry) {
-				if !a.ContainsKey(e.Key()) {
-					es.Put(e.Key(), e.Value())
-				}
-			})
-			if !a.Equals(es) {
-				return es, true
-			}
+			return deepMap(a, hb, pa, pb, o, path)
 		}
-
 	case dgo.Array:
-		if ab, ok := b.(dgo.Array); ok && ab.Len() > 0 {
-			if a.Len() == 0 {
-				return ab, true
+		if ab, ok := b.(dgo.Array); ok {
+			return deepArray(a, ab, pa, pb, o, path)
+		}
+	}
+	return a, pa, false
+}
+
+func deepMap(a, b dgo.Map, pa, pb *api.Provenance, o *deepOptions, path string) (dgo.Value, *api.Provenance, bool) {
+	pa, pb = provenances(pa, pb)
+	es := vf.MapWithCapacity(a.Len() + b.Len())
+	ep := entriesProvenance(a.Len()+b.Len(), pa, pb)
+	var knockedOut []dgo.Value
+	a.EachEntry(func(e dgo.MapEntry) {
+		k := e.Key()
+		if ks, ok := o.knockout(k); ok {
+			knockedOut = append(knockedOut, vf.String(ks))
+			return
+		}
+		v := e.Value()
+		if o.isKnockoutValue(v) {
+			knockedOut = append(knockedOut, k)
+			return
+		}
+		if bv := b.Get(k); bv != nil {
+			if m, mp, mh := deep(v, bv, entryProvenance(pa, k), entryProvenance(pb, k), o, childPath(path, k)); mh {
+				es.Put(k, m)
+				putEntry(ep, k, mp)
+				return
 			}
-			an := a.WithAll(ab).Unique()
-			if !an.Equals(a) {
-				return an, true
+		}
+		v, vp := o.withoutKnockouts(v, entryProvenance(pa, k))
+		es.Put(k, v)
+		putEntry(ep, k, vp)
+	})
+	b.EachEntry(func(e dgo.MapEntry) {
+		k := e.Key()
+		if !(a.ContainsKey(k) || containsValue(knockedOut, k)) {
+			es.Put(k, e.Value())
+			putEntry(ep, k, entryProvenance(pb, k))
+		}
+	})
+	if !a.Equals(es) {
+		return es, ep, true
+	}
+	return a, pa, false
+}
+
+func deepArray(a, b dgo.Array, pa, pb *api.Provenance, o *deepOptions, path string) (dgo.Value, *api.Provenance, bool) {
+	pa, pb = provenances(pa, pb)
+	policy := o.arrayPolicy(path)
+	if policy == unionArrays && o.mergeHashArrays && allMaps(a) && allMaps(b) {
+		return deepHashArray(a, b, pa, pb, o, path)
+	}
+
+	eas := elementProvenances(a, pa)
+	ebs := elementProvenances(b, pb)
+	var an dgo.Array
+	var aps []*api.Provenance
+	switch {
+	case o.knockoutPrefix != ``:
+		an, aps = o.knockoutArrays(policy, a, b, eas, ebs)
+	case policy != unionArrays:
+		an, aps = policy.combine(a, b, eas, ebs)
+	case b.Len() == 0:
+		return a, pa, false
+	case a.Len() == 0:
+		an, aps = b, ebs
+	default:
+		an, aps = policy.combine(a, b, eas, ebs)
+	}
+	if o.sortMergedArrays {
+		sorted := an.Sort()
+		aps = sortProvenance(an, sorted, aps)
+		an = sorted
+	}
+	if !an.Equals(a) {
+		return an, arrayProvenance(aps), true
+	}
+	return a, pa, false
+}
+
+// knockoutArrays combines the array a, without its knockout elements, with the array b, without the elements that
+// are knocked out by a, using the given policy. The pa and pb slices hold the provenances of the elements of a and b.
+func (o *deepOptions) knockoutArrays(policy arrayPolicy, a, b dgo.Array, pa, pb []*api.Provenance) (dgo.Array, []*api.Provenance) {
+	var knockedOut []dgo.Value
+	a.Each(func(e dgo.Value) {
+		if ks, ok := o.knockout(e); ok {
+			knockedOut = append(knockedOut, vf.String(ks))
+		}
+	})
+	isKnockedOut := func(e dgo.Value) bool { return containsValue(knockedOut, e) }
+	return policy.combine(
+		a.Reject(o.isKnockout), b.Reject(isKnockedOut), rejectProvenance(a, pa, o.isKnockout), rejectProvenance(b, pb, isKnockedOut))
+}
+
+// deepHashArray merges the hashes of the two arrays that are found at the same index.
+func deepHashArray(a, b dgo.Array, pa, pb *api.Provenance, o *deepOptions, path string) (dgo.Value, *api.Provenance, bool) {
+	top := a.Len()
+	if b.Len() > top {
+		top = b.Len()
+	}
+	eas := elementProvenances(a, pa)
+	ebs := elementProvenances(b, pb)
+	an := vf.ArrayWithCapacity(top)
+	var aps []*api.Provenance
+	if pa != nil {
+		aps = make([]*api.Provenance, 0, top)
+	}
+	for i := 0; i < top; i++ {
+		var v dgo.Value
+		var p *api.Provenance
+		switch {
+		case i >= a.Len():
+			v, p = b.Get(i), provenanceAt(ebs, i)
+		case i >= b.Len():
+			v, p = o.withoutKnockouts(a.Get(i), provenanceAt(eas, i))
+		default:
+			v, p, _ = deepMap(a.Get(i).(dgo.Map), b.Get(i).(dgo.Map), provenanceAt(eas, i), provenanceAt(ebs, i), o, path)
+		}
+		an.Add(v)
+		if aps != nil {
+			aps = append(aps, p)
+		}
+	}
+	if !a.Equals(an) {
+		return an, arrayProvenance(aps), true
+	}
+	return a, pa, false
+}
+
+// knockout returns the given value stripped from the knockout prefix and true if the value is a string
+// that starts with the knockout prefix.
+func (o *deepOptions) knockout(v dgo.Value) (string, bool) {
+	if o.knockoutPrefix != `` {
+		if s, ok := v.(dgo.String); ok {
+			gs := s.GoString()
+			if strings.HasPrefix(gs, o.knockoutPrefix) {
+				return gs[len(o.knockoutPrefix):], true
 			}
 		}
 	}
-	return a, false
+	return ``, false
+}
+
+// isKnockout returns true if the given value is a string that starts with the knockout prefix.
+func (o *deepOptions) isKnockout(v dgo.Value) bool {
+	_, ok := o.knockout(v)
+	return ok
+}
+
+// isKnockoutValue returns true if the given value is a string that is equal to the knockout prefix.
+func (o *deepOptions) isKnockoutValue(v dgo.Value) bool {
+	if o.knockoutPrefix != `` {
+		if s, ok := v.(dgo.String); ok {
+			return s.GoString() == o.knockoutPrefix
+		}
+	}
+	return false
+}
+
+// withoutKnockouts returns the given value with all knockout entries removed together with the provenance of the
+// result, given the provenance of the value
+func (o *deepOptions) withoutKnockouts(v dgo.Value, p *api.Provenance) (dgo.Value, *api.Provenance) {
+	if o.knockoutPrefix == `` {
+		return v, p
+	}
+	switch vt := v.(type) {
+	case dgo.Map:
+		v, p, _ = deepMap(vt, vf.Map(), p, nil, o, ``)
+	case dgo.Array:
+		if p != nil {
+			p = arrayProvenance(rejectProvenance(vt, elementProvenances(vt, p), o.isKnockout))
+		}
+		v = vt.Reject(o.isKnockout)
+	}
+	return v, p
+}
+
+// childPath returns the dotted path of the entry with the given key in the hash at the given path
+func childPath(path string, key dgo.Value) string {
+	if path == `` {
+		return key.String()
+	}
+	return path + `.` + key.String()
+}
+
+func allMaps(a dgo.Array) bool {
+	return a.All(func(e dgo.Value) bool {
+		_, ok := e.(dgo.Map)
+		return ok
+	})
+}
+
+// provenanceAt returns the provenance at the given index of the given provenances, or nil when provenance isn't
+// recorded
+func provenanceAt(ps []*api.Provenance, i int) *api.Provenance {
+	if ps == nil {
+		return nil
+	}
+	return ps[i]
+}
+
+func containsValue(vs []dgo.Value, v dgo.Value) bool {
+	for _, e := range vs {
+		if e.Equals(v) {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,154 @@
+package merge
+
+import (
+	"github.com/lyraproj/dgo/dgo"
+	"github.com/lyraproj/hiera/api"
+)
+
+// The functions in this file compute the provenance of merged values in parallel with the merge. They all return nil
+// when the given provenances are nil, which is the case unless provenance is recorded.
+
+// provenances returns the given provenances with an empty provenance, which has no sources, in place of a nil
+// provenance when the other provenance is recorded
+func provenances(pa, pb *api.Provenance) (*api.Provenance, *api.Provenance) {
+	switch {
+	case pa == nil && pb != nil:
+		pa = &api.Provenance{}
+	case pb == nil && pa != nil:
+		pb = &api.Provenance{}
+	}
+	return pa, pb
+}
+
+// entryProvenance returns the provenance of the entry with the given key in a hash with the given provenance
+func entryProvenance(p *api.Provenance, key dgo.Value) *api.Provenance {
+	if p == nil || p.Entries == nil {
+		return p
+	}
+	return p.Entries[key.String()]
+}
+
+// elementProvenances returns the provenance of each element of the given array that has the given provenance
+func elementProvenances(a dgo.Array, p *api.Provenance) []*api.Provenance {
+	if p == nil {
+		return nil
+	}
+	if p.Elements != nil {
+		return p.Elements
+	}
+	ps := make([]*api.Provenance, a.Len())
+	for i := range ps {
+		ps[i] = p
+	}
+	return ps
+}
+
+// arrayProvenance returns the provenance of an array with elements that have the given provenances
+func arrayProvenance(ps []*api.Provenance) *api.Provenance {
+	if ps == nil {
+		return nil
+	}
+	return &api.Provenance{Elements: ps}
+}
+
+// entriesProvenance creates the provenance of a hash that will contain n entries, or returns nil when neither of the
+// given provenances is recorded
+func entriesProvenance(n int, pa, pb *api.Provenance) *api.Provenance {
+	if pa == nil && pb == nil {
+		return nil
+	}
+	return &api.Provenance{Entries: make(map[string]*api.Provenance, n)}
+}
+
+// putEntry sets the provenance of the entry with the given key in the given hash provenance
+func putEntry(p *api.Provenance, key dgo.Value, ep *api.Provenance) {
+	if p != nil {
+		p.Entries[key.String()] = ep
+	}
+}
+
+// flattenProvenance returns the provenances of the elements of the flattened form of the given array that has the
+// given provenance
+func flattenProvenance(a dgo.Array, p *api.Provenance) []*api.Provenance {
+	if p == nil {
+		return nil
+	}
+	eps := elementProvenances(a, p)
+	fps := make([]*api.Provenance, 0, len(eps))
+	a.EachWithIndex(func(e dgo.Value, i int) {
+		if ea, ok := e.(dgo.Array); ok {
+			fps = append(fps, flattenProvenance(ea, eps[i])...)
+		} else {
+			fps = append(fps, eps[i])
+		}
+	})
+	return fps
+}
+
+// uniqueProvenance returns the provenances of the elements of the array that dgo.Array.Unique returns for the given
+// array, i.e. the provenance of the first occurrence of each element
+func uniqueProvenance(a dgo.Array, ps []*api.Provenance) []*api.Provenance {
+	if ps == nil {
+		return nil
+	}
+	ups := make([]*api.Provenance, 0, len(ps))
+	a.EachWithIndex(func(e dgo.Value, i int) {
+		if a.IndexOf(e) == i {
+			ups = append(ups, ps[i])
+		}
+	})
+	return ups
+}
+
+// rejectProvenance returns the provenances of the elements of the given array that are not rejected by the given
+// predicate
+func rejectProvenance(a dgo.Array, ps []*api.Provenance, predicate dgo.Predicate) []*api.Provenance {
+	if ps == nil {
+		return nil
+	}
+	rps := make([]*api.Provenance, 0, len(ps))
+	a.EachWithIndex(func(e dgo.Value, i int) {
+		if !predicate(e) {
+			rps = append(rps, ps[i])
+		}
+	})
+	return rps
+}
+
+// sortProvenance returns the provenances of the elements of the array sorted, which is the result of sorting the given
+// array. The sort is stable so equal elements are matched in the order they appear in the given array.
+func sortProvenance(a, sorted dgo.Array, ps []*api.Provenance) []*api.Provenance {
+	if ps == nil {
+		return nil
+	}
+	used := make([]bool, a.Len())
+	sps := make([]*api.Provenance, 0, len(ps))
+	sorted.Each(func(e dgo.Value) {
+		for i := range used {
+			if !used[i] && e.Equals(a.Get(i)) {
+				used[i] = true
+				sps = append(sps, ps[i])
+				return
+			}
+		}
+	})
+	return sps
+}
+
+// mergedProvenance returns the provenance of a value that was merged from values with the given provenances in a way
+// that cannot be attributed to the parts of those values
+func mergedProvenance(pa, pb *api.Provenance) *api.Provenance {
+	if pa == nil && pb == nil {
+		return nil
+	}
+	return (&api.Provenance{Elements: []*api.Provenance{pa, pb}}).Collapse()
+}
+
+// concat returns the concatenation of the given provenances
+func concat(a, b []*api.Provenance) []*api.Provenance {
+	if a == nil && b == nil {
+		return nil
+	}
+	c := make([]*api.Provenance, 0, len(a)+len(b))
+	return append(append(c, a...), b...)
+}
@@ -0,0 +1,118 @@
+package merge
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/lyraproj/dgo/dgo"
+	"github.com/lyraproj/hiera/api"
+)
+
+// A MergeFunc merges the value a with the value b and returns the result. The value a is the result of
+// merging all values found so far and always has higher priority than b. The options are the options that
+// were given to the strategy, i.e. the merge option or lookup_options merge hash without the "strategy" key.
+type MergeFunc func(a, b dgo.Value, options dgo.Map) dgo.Value
+
+// A ConvertFunc converts a found value before it is merged with other values. It is called with the
+// first value that is found and also with the value when only one value is found. A nil return means that
+// the value is used as is.
+type ConvertFunc func(v dgo.Value, options dgo.Map) dgo.Value
+
+type (
+	factory func(opts dgo.Map) api.MergeStrategy
+
+	registered struct {
+		name        string
+		label       string
+		mergeFunc   MergeFunc
+		convertFunc ConvertFunc
+		opts        dgo.Map
+	}
+)
+
+var registryLock sync.RWMutex
+
+var registryNames = []string{`first`, `unique`, `hash`, `deep`}
+
+var registry = map[string]factory{
+	`first`:  func(_ dgo.Map) api.MergeStrategy { return &firstFound{} },
+	`unique`: func(opts dgo.Map) api.MergeStrategy { return newUnique(opts) },
+	`hash`:   func(_ dgo.Map) api.MergeStrategy { return &hashMerge{} },
+	`deep`:   func(opts dgo.Map) api.MergeStrategy { return &deepMerge{opts} },
+}
+
+// Register registers a merge strategy under the given name so that it can be used in the same way as the
+// built in strategies, i.e. as the merge option of a lookup, in a lookup_options hash, or with the CLI --merge
+// flag. The label is a short description that is used by the explainer. The convertFunc is optional and may
+// be nil.
+//
+// A panic is raised if a strategy with the given name is already registered.
+func Register(name, label string, mergeFunc MergeFunc, convertFunc ConvertFunc) {
+	if mergeFunc == nil {
+		panic(fmt.Errorf(`merge strategy '%s' must have a merge function`, name))
+	}
+	registryLock.Lock()
+	defer registryLock.Unlock()
+	if _, ok := registry[name]; ok {
+		panic(fmt.Errorf(`merge strategy '%s' is already registered`, name))
+	}
+	registry[name] = func(opts dgo.Map) api.MergeStrategy {
+		return &registered{name: name, label: label, mergeFunc: mergeFunc, convertFunc: convertFunc, opts: opts}
+	}
+	registryNames = append(registryNames, name)
+}
+
+// IsRegistered returns true if a merge strategy with the given name is registered.
+func IsRegistered(name string) bool {
+	registryLock.RLock()
+	_, ok := registry[name]
+	registryLock.RUnlock()
+	return ok
+}
+
+// Names returns the names of all registered merge strategies in the order they were registered.
+func Names() []string {
+	registryLock.RLock()
+	names := make([]string, len(registryNames))
+	copy(names, registryNames)
+	registryLock.RUnlock()
+	return names
+}
+
+func (d *registered) Name() string {
+	return d.name
+}
+
+func (d *registered) Label() string {
+	return d.label
+}
+
+func (d *registered) MergeLookup(vs interface{}, ic api.Invocation, f func(location interface{}) dgo.Value) dgo.Value {
+	return doLookup(d, vs, ic, f)
+}
+
+func (d *registered) Options() dgo.Map {
+	return d.opts
+}
+
+func (d *registered) mergeSingle(v dgo.Value, p *api.Provenance) (dgo.Value, *api.Provenance) {
+	return d.convertValue(v, p)
+}
+
+// convertValue converts the given value using the convert function. The provenance of a converted value that
+// differs from the given value is collapsed since the parts of the result cannot be attributed to the parts of the
+// given value.
+func (d *registered) convertValue(v dgo.Value, p *api.Provenance) (dgo.Value, *api.Provenance) {
+	if d.convertFunc != nil {
+		if cv := d.convertFunc(v, d.opts); cv != nil && !cv.Equals(v) {
+			return cv, p.Collapse()
+		}
+	}
+	return v, p
+}
+
+// merge merges the given values using the merge function. The parts of the result cannot be attributed to the parts
+// of the given values so the provenance of the result has all sources of both values.
+func (d *registered) merge(a, b dgo.Value, pa, pb *api.Provenance) (dgo.Value, *api.Provenance) {
+	return d.mergeFunc(a, b, d.opts), mergedProvenance(pa, pb)
+}
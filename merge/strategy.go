@@ -16,37 +16,38 @@ type (
 
 	firstFound struct{}
 
-	unique struct{}
+	unique struct {
+		opts   dgo.Map
+		policy arrayPolicy
+	}
 )
 
-// GetStrategy returns the merge.MergeStrategy that corresponds to the given name. The
-// options argument is only applicable to deep merge
+// GetStrategy returns the merge.MergeStrategy that corresponds to the given name. The options
+// argument is passed on to the strategy. The built in first and hash strategies ignores the options.
+// A panic is raised if no strategy has been registered using the given name.
 func GetStrategy(n string, opts dgo.Map) api.MergeStrategy {
-	switch n {
-	case `first`:
-		return &firstFound{}
-	case `unique`:
-		return &unique{}
-	case `hash`:
-		return &hashMerge{}
-	case `deep`:
-		if opts == nil {
-			opts = vf.Map()
-		}
-		return &deepMerge{opts}
-	default:
+	registryLock.RLock()
+	f, ok := registry[n]
+	registryLock.RUnlock()
+	if !ok {
 		panic(fmt.Errorf(`unknown merge strategy '%s'`, n))
 	}
+	if opts == nil {
+		opts = vf.Map()
+	}
+	return f(opts)
 }
 
+// A merger merges values together with their provenance. The provenance of a value is nil unless provenance is
+// recorded, and the provenance of the result must then be nil too.
 type merger interface {
 	api.MergeStrategy
 
-	merge(a, b dgo.Value) dgo.Value
+	merge(a, b dgo.Value, pa, pb *api.Provenance) (dgo.Value, *api.Provenance)
 
-	mergeSingle(v reflect.Value, vf func(l interface{}) dgo.Value) dgo.Value
+	mergeSingle(v dgo.Value, p *api.Provenance) (dgo.Value, *api.Provenance)
 
-	convertValue(v dgo.Value) dgo.Value
+	convertValue(v dgo.Value, p *api.Provenance) (dgo.Value, *api.Provenance)
 }
 
 func doLookup(s merger, vs interface{}, ic api.Invocation, vf func(l interface{}) dgo.Value) dgo.Value {
@@ -59,20 +60,30 @@ func doLookup(s merger, vs interface{}, ic api.Invocation, vf func(l interface{}
 	case 0:
 		return nil
 	case 1:
-		return s.mergeSingle(vsr.Index(0), vf)
+		v := variantLookup(vsr.Index(0), vf)
+		if v != nil {
+			var p *api.Provenance
+			v, p = s.mergeSingle(v, ic.TakeProvenance())
+			ic.SetProvenance(p)
+		}
+		return v
 	default:
 		return ic.WithMerge(s, func() dgo.Value {
 			var memo dgo.Value
+			var memoP *api.Provenance
 			for idx := 0; idx < top; idx++ {
 				v := variantLookup(vsr.Index(idx), vf)
+				p := ic.TakeProvenance()
 				if v != nil {
 					if memo == nil {
-						memo = s.convertValue(v)
+						memo, memoP = s.convertValue(v, p)
 					} else {
-						memo = s.merge(memo, v)
+						memoP, p = provenances(memoP, p)
+						memo, memoP = s.merge(memo, v, memoP, p)
 					}
 				}
 			}
+			ic.SetProvenance(memoP)
 			if memo != nil {
 				ic.ReportMergeResult(memo)
 			}
@@ -126,16 +137,26 @@ func (d *firstFound) Options() dgo.Map {
 	return vf.Map()
 }
 
-func (d *firstFound) mergeSingle(v reflect.Value, vf func(l interface{}) dgo.Value) dgo.Value {
-	return variantLookup(v, vf)
+func (d *firstFound) mergeSingle(v dgo.Value, p *api.Provenance) (dgo.Value, *api.Provenance) {
+	return v, p
 }
 
-func (d *firstFound) convertValue(v dgo.Value) dgo.Value {
-	return v
+func (d *firstFound) convertValue(v dgo.Value, p *api.Provenance) (dgo.Value, *api.Provenance) {
+	return v, p
 }
 
-func (d *firstFound) merge(a, b dgo.Value) dgo.Value {
-	return a
+func (d *firstFound) merge(a, b dgo.Value, pa, pb *api.Provenance) (dgo.Value, *api.Provenance) {
+	return a, pa
+}
+
+// newUnique creates the unique merge strategy. The array_merge option of that strategy must be a string since
+// the merged values are always arrays.
+func newUnique(opts dgo.Map) *unique {
+	u := &unique{opts: opts, policy: unionArrays}
+	if am := opts.Get(`array_merge`); am != nil {
+		u.policy = parseArrayPolicy(am)
+	}
+	return u
 }
 
 func (d *unique) Name() string {
@@ -151,26 +172,40 @@ func (d *unique) MergeLookup(vs interface{}, ic api.Invocation, f func(location
 }
 
 func (d *unique) Options() dgo.Map {
-	return vf.Map()
+	return d.opts
 }
 
-func (d *unique) mergeSingle(rv reflect.Value, vf func(l interface{}) dgo.Value) dgo.Value {
-	v := variantLookup(rv, vf)
+func (d *unique) mergeSingle(v dgo.Value, p *api.Provenance) (dgo.Value, *api.Provenance) {
 	if av, ok := v.(dgo.Array); ok {
-		return av.Flatten().Unique()
+		fa := av.Flatten()
+		ps := flattenProvenance(av, p)
+		if d.policy == unionArrays {
+			ps = uniqueProvenance(fa, ps)
+			fa = fa.Unique()
+		}
+		return fa, arrayProvenance(ps)
 	}
-	return v
+	return v, p
 }
 
-func (d *unique) convertValue(v dgo.Value) dgo.Value {
+func (d *unique) convertValue(v dgo.Value, p *api.Provenance) (dgo.Value, *api.Provenance) {
 	if av, ok := v.(dgo.Array); ok {
-		return av.Flatten()
+		return av.Flatten(), arrayProvenance(flattenProvenance(av, p))
 	}
-	return vf.Values(v)
+	var ps []*api.Provenance
+	if p != nil {
+		ps = []*api.Provenance{p}
+	}
+	return vf.Values(v), arrayProvenance(ps)
 }
 
-func (d *unique) merge(a, b dgo.Value) dgo.Value {
-	return d.convertValue(a).(dgo.Array).WithAll(d.convertValue(b).(dgo.Array)).Unique()
+func (d *unique) merge(a, b dgo.Value, pa, pb *api.Provenance) (dgo.Value, *api.Provenance) {
+	ca, pa := d.convertValue(a, pa)
+	cb, pb := d.convertValue(b, pb)
+	aa := ca.(dgo.Array)
+	ab := cb.(dgo.Array)
+	c, ps := d.policy.combine(aa, ab, elementProvenances(aa, pa), elementProvenances(ab, pb))
+	return c, arrayProvenance(ps)
 }
 
 func (d *deepMerge) Name() string {
@@ -189,17 +224,17 @@ func (d *deepMerge) Options() dgo.Map {
 	return d.opts
 }
 
-func (d *deepMerge) mergeSingle(v reflect.Value, vf func(l interface{}) dgo.Value) dgo.Value {
-	return variantLookup(v, vf)
+func (d *deepMerge) mergeSingle(v dgo.Value, p *api.Provenance) (dgo.Value, *api.Provenance) {
+	return v, p
 }
 
-func (d *deepMerge) convertValue(v dgo.Value) dgo.Value {
-	return v
+func (d *deepMerge) convertValue(v dgo.Value, p *api.Provenance) (dgo.Value, *api.Provenance) {
+	return v, p
 }
 
-func (d *deepMerge) merge(a, b dgo.Value) dgo.Value {
-	v, _ := Deep(a, b, d.opts)
-	return v
+func (d *deepMerge) merge(a, b dgo.Value, pa, pb *api.Provenance) (dgo.Value, *api.Provenance) {
+	v, p, _ := deep(a, b, pa, pb, newDeepOptions(d.opts), ``)
+	return v, p
 }
 
 func (d *hashMerge) Name() string {
@@ -218,20 +253,31 @@ func (d *hashMerge) Options() dgo.Map {
 	return vf.Map()
 }
 
-func (d *hashMerge) mergeSingle(v reflect.Value, vf func(l interface{}) dgo.Value) dgo.Value {
-	return variantLookup(v, vf)
+func (d *hashMerge) mergeSingle(v dgo.Value, p *api.Provenance) (dgo.Value, *api.Provenance) {
+	return v, p
 }
 
-func (d *hashMerge) convertValue(v dgo.Value) dgo.Value {
-	return v
+func (d *hashMerge) convertValue(v dgo.Value, p *api.Provenance) (dgo.Value, *api.Provenance) {
+	return v, p
 }
 
-func (d *hashMerge) merge(a, b dgo.Value) dgo.Value {
+func (d *hashMerge) merge(a, b dgo.Value, pa, pb *api.Provenance) (dgo.Value, *api.Provenance) {
 	if ah, ok := a.(dgo.Map); ok {
 		var bh dgo.Map
 		if bh, ok = b.(dgo.Map); ok {
-			return bh.Merge(ah)
+			m := bh.Merge(ah)
+			p := entriesProvenance(m.Len(), pa, pb)
+			if p != nil {
+				m.EachKey(func(k dgo.Value) {
+					if ah.ContainsKey(k) {
+						putEntry(p, k, entryProvenance(pa, k))
+					} else {
+						putEntry(p, k, entryProvenance(pb, k))
+					}
+				})
+			}
+			return m, p
 		}
 	}
-	return a
+	return a, pa
 }
@@ -0,0 +1,189 @@
+// Package metrics contains the metrics that Hiera collects about lookups, hierarchy levels, plugin calls, and caches,
+// and the http.Handler that serves them to Prometheus. Nothing is collected until Enable is called.
+package metrics
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Lookup results used with ObserveLookup
+const (
+	Found    = `found`
+	NotFound = `not_found`
+	Error    = `error`
+)
+
+// Caches used with ObserveCache
+const (
+	// ConfigCache is the cache of parsed hiera.yaml files
+	ConfigCache = `config`
+
+	// DataHashCache is the cache of the hashes returned by data_hash functions
+	DataHashCache = `data_hash`
+
+	// ProviderCache is the cache that lookup functions access using api.ServerContext
+	ProviderCache = `provider`
+)
+
+// OtherRoot is the root label used for lookups that didn't find a value and for lookups of roots beyond MaxRoots
+const OtherRoot = `other`
+
+// MaxRoots is the maximum number of distinct key roots that are used as the root label of the lookup metrics. Only
+// the roots of lookups that found a value are used, since clients may look up arbitrary keys. All other lookups use
+// the root label OtherRoot so that the number of time series stays bounded.
+var MaxRoots = 200
+
+// DefaultBuckets are the upper bounds in seconds of the buckets of all histograms
+var DefaultBuckets = []float64{.0005, .001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+var enabled int32
+
+var (
+	lookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: `hiera_lookups_total`,
+		Help: `Number of lookups by key root and result (found, not_found, or error).`,
+	}, []string{`root`, `result`})
+
+	lookupDurations = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    `hiera_lookup_duration_seconds`,
+		Help:    `Duration of lookups by key root.`,
+		Buckets: DefaultBuckets,
+	}, []string{`root`})
+
+	levelDurations = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    `hiera_hierarchy_level_duration_seconds`,
+		Help:    `Time spent searching a hierarchy level, by level name.`,
+		Buckets: DefaultBuckets,
+	}, []string{`level`})
+
+	pluginDurations = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    `hiera_plugin_call_duration_seconds`,
+		Help:    `Duration of calls to plugin functions, by function name.`,
+		Buckets: DefaultBuckets,
+	}, []string{`function`})
+
+	cacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: `hiera_cache_requests_total`,
+		Help: `Number of cache requests by cache and result (hit or miss).`,
+	}, []string{`cache`, `result`})
+
+	cacheHitRatios = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: `hiera_cache_hit_ratio`,
+		Help: `Ratio of cache requests that were hits, by cache.`,
+	}, []string{`cache`})
+)
+
+var registry = prometheus.NewRegistry()
+
+func init() {
+	registry.MustRegister(lookups, lookupDurations, levelDurations, pluginDurations, cacheRequests, cacheHitRatios)
+}
+
+// roots holds the key roots that are used as the root label
+var roots = struct {
+	lock  sync.Mutex
+	known map[string]bool
+}{known: map[string]bool{}}
+
+// cacheCounts holds the number of hits and the total number of requests of each cache so that the hit ratio can be
+// computed
+var cacheCounts = struct {
+	lock   sync.Mutex
+	hits   map[string]float64
+	totals map[string]float64
+}{hits: map[string]float64{}, totals: map[string]float64{}}
+
+// Enable enables the collection of metrics
+func Enable() {
+	atomic.StoreInt32(&enabled, 1)
+}
+
+// Enabled returns true if metrics are collected
+func Enabled() bool {
+	return atomic.LoadInt32(&enabled) != 0
+}
+
+// Reset discards all collected metrics
+func Reset() {
+	lookups.Reset()
+	lookupDurations.Reset()
+	roots.lock.Lock()
+	roots.known = map[string]bool{}
+	roots.lock.Unlock()
+	levelDurations.Reset()
+	pluginDurations.Reset()
+	cacheCounts.lock.Lock()
+	cacheRequests.Reset()
+	cacheHitRatios.Reset()
+	cacheCounts.hits = map[string]float64{}
+	cacheCounts.totals = map[string]float64{}
+	cacheCounts.lock.Unlock()
+}
+
+// Handler returns the http.Handler that serves all metrics using the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// ObserveLookup records a lookup of a key with the given root that resulted in Found, NotFound, or Error and that
+// started at the given time. See MaxRoots for how the root label is determined.
+func ObserveLookup(root, result string, start time.Time) {
+	if Enabled() {
+		root = rootLabel(root, result)
+		lookups.WithLabelValues(root, result).Inc()
+		lookupDurations.WithLabelValues(root).Observe(time.Since(start).Seconds())
+	}
+}
+
+// rootLabel returns the given root if the lookup found a value and the root is known or can be added without
+// exceeding MaxRoots, and OtherRoot otherwise
+func rootLabel(root, result string) string {
+	if result != Found {
+		return OtherRoot
+	}
+	roots.lock.Lock()
+	defer roots.lock.Unlock()
+	if !roots.known[root] {
+		if len(roots.known) >= MaxRoots {
+			return OtherRoot
+		}
+		roots.known[root] = true
+	}
+	return root
+}
+
+// ObserveLevel records the time spent searching the hierarchy level with the given name, starting at the given time
+func ObserveLevel(level string, start time.Time) {
+	if Enabled() {
+		levelDurations.WithLabelValues(level).Observe(time.Since(start).Seconds())
+	}
+}
+
+// ObservePluginCall records a call to the plugin function with the given name that started at the given time
+func ObservePluginCall(function string, start time.Time) {
+	if Enabled() {
+		pluginDurations.WithLabelValues(function).Observe(time.Since(start).Seconds())
+	}
+}
+
+// ObserveCache records a hit or a miss in the given cache and updates the hit ratio of that cache
+func ObserveCache(cache string, hit bool) {
+	if Enabled() {
+		result := `miss`
+		cacheCounts.lock.Lock()
+		if hit {
+			result = `hit`
+			cacheCounts.hits[cache]++
+		}
+		cacheCounts.totals[cache]++
+		cacheRequests.WithLabelValues(cache, result).Inc()
+		cacheHitRatios.WithLabelValues(cache).Set(cacheCounts.hits[cache] / cacheCounts.totals[cache])
+		cacheCounts.lock.Unlock()
+	}
+}
@@ -16,24 +16,61 @@ func ConfigLookupKey(pc hiera.ProviderContext, key string) dgo.Value {
 }
 
 // ConfigLookupKeyAt performs a lookup based on a hierarchy of providers that has been specified
-// in a yaml based configuration appointed by the given configPath.
+// in a yaml based configuration appointed by the given configPath. The default_hierarchy of the
+// configuration is searched when no value is found in the hierarchy.
 func ConfigLookupKeyAt(sc api.ServerContext, configPath, key, moduleName string) dgo.Value {
 	ic := sc.Invocation()
 	cfg := ic.Config(configPath, moduleName)
 	k := api.NewKey(key)
 	if ic.LookupOptionsMode() {
-		return cfg.LookupOptions(k)
+		lo, pattern := cfg.MatchLookupOptions(k)
+		if pattern != `` {
+			ic.ReportLookupOptionsPattern(pattern)
+		}
+		return lo
 	}
 
 	if ic.DataMode() {
-		return ic.MergeHierarchy(k, cfg.Hierarchy(), ic.MergeStrategy())
+		if v := ic.MergeHierarchy(k, cfg.Hierarchy(), ic.MergeStrategy()); v != nil {
+			return v
+		}
+		return lookupInDefaultHierarchy(sc, cfg, k)
 	}
 
 	ic = sc.Invocation().ForData()
 	return ic.WithLookup(k, func() dgo.Value {
-		ic.SetMergeStrategy(sc.Option(`merge`), cfg.LookupOptions(k))
-		return ic.LookupAndConvertData(func() dgo.Value {
+		lo, pattern := cfg.MatchLookupOptions(k)
+		if pattern != `` {
+			ic.ReportLookupOptionsPattern(pattern)
+		}
+		ic.SetMergeStrategy(sc.Option(`merge`), lo)
+		v := ic.LookupAndConvertData(func() dgo.Value {
 			return ic.MergeHierarchy(k, cfg.Hierarchy(), ic.MergeStrategy())
 		})
+		if v == nil {
+			v = lookupInDefaultHierarchy(sc, cfg, k)
+		}
+		return v
+	})
+}
+
+// lookupInDefaultHierarchy performs a lookup in the default_hierarchy of the given config. The merge strategy and
+// conversion is determined by the lookup_options found in the default_hierarchy unless a merge option is given
+// explicitly.
+func lookupInDefaultHierarchy(sc api.ServerContext, cfg api.ResolvedConfig, k api.Key) dgo.Value {
+	dps := cfg.DefaultHierarchy()
+	if len(dps) == 0 {
+		return nil
+	}
+	ic := sc.Invocation().ForDefaultHierarchy()
+	return ic.WithDefaultHierarchy(func() dgo.Value {
+		lo, pattern := cfg.MatchDefaultLookupOptions(k)
+		if pattern != `` {
+			ic.ReportLookupOptionsPattern(pattern)
+		}
+		ic.SetMergeStrategy(sc.Option(`merge`), lo)
+		return ic.LookupAndConvertData(func() dgo.Value {
+			return ic.MergeHierarchy(k, dps, ic.MergeStrategy())
+		})
 	})
 }
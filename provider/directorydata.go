@@ -0,0 +1,137 @@
+package provider
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lyraproj/dgo/dgo"
+	"github.com/lyraproj/dgo/streamer"
+	"github.com/lyraproj/dgo/vf"
+	"github.com/lyraproj/dgoyaml/yaml"
+	"github.com/lyraproj/hiera/api"
+	"github.com/lyraproj/hierasdk/hiera"
+)
+
+// DirectoryDecode is the option that enables decoding of files with a .yaml, .yml, or .json extension in
+// DirectoryData
+const DirectoryDecode = `decode`
+
+// DirectorySensitive is the option that makes DirectoryData return all values as Sensitive values
+const DirectorySensitive = `sensitive`
+
+// DirectoryData is a LookupKey function that treats each file in the directory appointed by the "path" option as
+// a key and the content of that file as its value. This is how Kubernetes ConfigMap and Secret volumes and Docker
+// secrets are presented. A single trailing newline is removed from the content. A subdirectory is returned as a Map
+// where each file is an entry, so the file db/port can be found using the dotted key "db.port". Files and
+// directories with names that start with a '.' are ignored.
+//
+// When the "decode" option is true, files with a .yaml, .yml, or .json extension are decoded and the key is the
+// name of the file without the extension. When the "sensitive" option is true, all values are returned as
+// Sensitive values.
+func DirectoryData(pc hiera.ProviderContext, key string) dgo.Value {
+	pv := pc.Option(`path`)
+	if pv == nil {
+		panic(api.MissingRequiredOption(`path`))
+	}
+	if key == `` || strings.HasPrefix(key, `.`) || strings.ContainsAny(key, `/\`) {
+		return nil
+	}
+	dr := &directoryReader{decode: boolOption(pc, DirectoryDecode)}
+	v := dr.entry(pv.String(), key)
+	if v != nil && boolOption(pc, DirectorySensitive) {
+		v = vf.Sensitive(v)
+	}
+	return v
+}
+
+type directoryReader struct {
+	decode bool
+}
+
+var decodedExtensions = []string{`.yaml`, `.yml`, `.json`}
+
+// entry returns the value of the entry with the given name in the given directory, or nil if no such entry exists
+func (dr *directoryReader) entry(dir, name string) dgo.Value {
+	fp := filepath.Join(dir, name)
+	if fi, err := os.Stat(fp); err == nil {
+		if fi.IsDir() {
+			return dr.directory(fp)
+		}
+		return dr.file(fp)
+	}
+	if dr.decode {
+		for _, ext := range decodedExtensions {
+			if fi, err := os.Stat(fp + ext); err == nil && !fi.IsDir() {
+				return dr.file(fp + ext)
+			}
+		}
+	}
+	return nil
+}
+
+func (dr *directoryReader) directory(dir string) dgo.Map {
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		panic(fmt.Errorf("could not read %s: %s", dir, err.Error()))
+	}
+	m := vf.MapWithCapacity(len(fis))
+	for _, fi := range fis {
+		name := fi.Name()
+		if strings.HasPrefix(name, `.`) {
+			continue
+		}
+		if dr.decode {
+			if ext := filepath.Ext(name); isDecodedExtension(ext) {
+				if v := dr.entry(dir, name); v != nil {
+					m.Put(strings.TrimSuffix(name, ext), v)
+				}
+				continue
+			}
+		}
+		if v := dr.entry(dir, name); v != nil {
+			m.Put(name, v)
+		}
+	}
+	return m
+}
+
+func (dr *directoryReader) file(path string) dgo.Value {
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		panic(fmt.Errorf("could not read %s: %s", path, err.Error()))
+	}
+	if dr.decode {
+		switch filepath.Ext(path) {
+		case `.json`:
+			return streamer.UnmarshalJSON(bs, nil)
+		case `.yaml`, `.yml`:
+			v, err := yaml.Unmarshal(bs)
+			if err != nil {
+				panic(fmt.Errorf("could not unmarshal %s: %s", path, err.Error()))
+			}
+			return v
+		}
+	}
+	s := string(bs)
+	if strings.HasSuffix(s, "\n") {
+		s = strings.TrimSuffix(strings.TrimSuffix(s, "\n"), "\r")
+	}
+	return vf.String(s)
+}
+
+func isDecodedExtension(ext string) bool {
+	for _, de := range decodedExtensions {
+		if ext == de {
+			return true
+		}
+	}
+	return false
+}
+
+func boolOption(pc hiera.ProviderContext, name string) bool {
+	b, ok := pc.Option(name).(dgo.Boolean)
+	return ok && b.GoBool()
+}
@@ -0,0 +1,84 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/lyraproj/dgo/dgo"
+	"github.com/lyraproj/dgo/vf"
+	"github.com/lyraproj/hierasdk/hiera"
+)
+
+// DotenvData is a data_hash provider that reads a .env file and returns its variables as a Map of strings.
+//
+// Each line of the file is a NAME=value assignment, optionally preceded by "export". Empty lines and lines starting
+// with '#' are ignored. A value can be enclosed in single quotes, in which case it is taken literally, or in double
+// quotes, in which case the escapes \n, \t, \", and \\ are recognized. A '#' that is preceded by whitespace starts
+// a comment in an unquoted value.
+func DotenvData(ctx hiera.ProviderContext) dgo.Map {
+	path, bs := readDataFile(ctx)
+	if bs == nil {
+		return vf.Map()
+	}
+	data := vf.MapWithCapacity(bytes.Count(bs, []byte{'\n'}) + 1)
+	s := bufio.NewScanner(bytes.NewReader(bs))
+	for ln := 1; s.Scan(); ln++ {
+		line := strings.TrimSpace(s.Text())
+		if line == `` || strings.HasPrefix(line, `#`) {
+			continue
+		}
+		line = strings.TrimPrefix(line, `export `)
+		ei := strings.IndexRune(line, '=')
+		if ei <= 0 {
+			panic(fmt.Errorf("%s:%d: expected NAME=value", path, ln))
+		}
+		v, ok := dotenvValue(strings.TrimSpace(line[ei+1:]))
+		if !ok {
+			panic(fmt.Errorf("%s:%d: unterminated quoted value", path, ln))
+		}
+		data.Put(strings.TrimSpace(line[:ei]), v)
+	}
+	return data
+}
+
+func dotenvValue(v string) (string, bool) {
+	if v == `` {
+		return v, true
+	}
+	switch v[0] {
+	case '\'':
+		end := strings.IndexRune(v[1:], '\'')
+		if end < 0 {
+			return ``, false
+		}
+		return v[1 : end+1], true
+	case '"':
+		b := strings.Builder{}
+		for i := 1; i < len(v); i++ {
+			c := v[i]
+			switch {
+			case c == '"':
+				return b.String(), true
+			case c == '\\' && i+1 < len(v):
+				i++
+				switch v[i] {
+				case 'n':
+					c = '\n'
+				case 't':
+					c = '\t'
+				default:
+					c = v[i]
+				}
+			}
+			b.WriteByte(c)
+		}
+		return ``, false
+	default:
+		if ci := strings.Index(v, ` #`); ci >= 0 {
+			v = strings.TrimSpace(v[:ci])
+		}
+		return v, true
+	}
+}
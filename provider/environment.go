@@ -2,6 +2,7 @@ package provider
 
 import (
 	"os"
+	"sort"
 	"strings"
 
 	"github.com/lyraproj/dgo/dgo"
@@ -13,7 +14,18 @@ import (
 // "env" in which case all current environment variables will be returned as an OrderedMap, or
 // prefixed with "env::" in which case the rest of the key is interpreted as the environment variable
 // to look for.
-func Environment(_ hiera.ProviderContext, key string) dgo.Value {
+//
+// When the "env_prefix" option is set, a key that equals the lower case prefix returns a Map built from all
+// environment variables that start with the prefix followed by "__". The double underscore separates the names
+// of nested keys, so with the prefix "APP", the variable APP__DB__PORT=5432 becomes the entry "db.port" of the
+// Map returned for the key "app". Values that can be parsed as integers, floats, booleans, or RFC3339 timestamps
+// are converted.
+func Environment(pc hiera.ProviderContext, key string) dgo.Value {
+	if pv := pc.Option(EnvPrefix); pv != nil {
+		if prefix := pv.String(); key == strings.ToLower(prefix) {
+			return prefixedEnvironment(prefix)
+		}
+	}
 	if key == `env` {
 		env := os.Environ()
 		em := vf.MapWithCapacity(len(env))
@@ -32,3 +44,43 @@ func Environment(_ hiera.ProviderContext, key string) dgo.Value {
 	}
 	return nil
 }
+
+// EnvPrefix is the option that appoints the prefix of environment variables that are mapped to nested keys
+const EnvPrefix = `env_prefix`
+
+const envSeparator = `__`
+
+func prefixedEnvironment(prefix string) dgo.Value {
+	prefix += envSeparator
+	var names []string
+	for _, ev := range os.Environ() {
+		if strings.HasPrefix(ev, prefix) {
+			names = append(names, ev)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	// Sorting ensures that a variable that maps to a hash replaces a variable that maps to a scalar at the same key
+	sort.Strings(names)
+	em := vf.MapWithCapacity(len(names))
+	for _, ev := range names {
+		ei := strings.IndexRune(ev, '=')
+		if ei < 0 {
+			continue
+		}
+		path := strings.Split(strings.ToLower(ev[len(prefix):ei]), envSeparator)
+		m := em
+		for _, k := range path[:len(path)-1] {
+			nm, ok := m.Get(k).(dgo.Map)
+			if !ok {
+				nm = vf.MapWithCapacity(1)
+				m.Put(k, nm)
+			}
+			m = nm
+		}
+		m.Put(path[len(path)-1], scalarValue(ev[ei+1:]))
+	}
+	return em
+}
@@ -0,0 +1,176 @@
+package provider
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"github.com/lyraproj/dgo/dgo"
+	"github.com/lyraproj/dgo/vf"
+	"github.com/lyraproj/hiera/api"
+	"github.com/lyraproj/hierasdk/hiera"
+	"go.mozilla.org/pkcs7"
+)
+
+// EyamlPrivateKey is the option that appoints the PEM encoded private key used by EyamlLookupKey
+const EyamlPrivateKey = `pkcs7_private_key`
+
+// EyamlPublicKey is the option that appoints the PEM encoded public key (certificate) used by EyamlLookupKey
+const EyamlPublicKey = `pkcs7_public_key`
+
+var encPattern = regexp.MustCompile(`ENC\[(\w+),([A-Za-z0-9+/=\s]+)\]`)
+
+// EyamlLookupKey is a LookupKey function that reads a YAML hash from the file appointed by the "path" option and
+// decrypts hiera-eyaml "ENC[PKCS7,...]" blocks found in the value of the given key. The blocks are decrypted using
+// the keys appointed by the "pkcs7_private_key" and "pkcs7_public_key" options. Values that contain encrypted
+// blocks are returned as Sensitive values.
+func EyamlLookupKey(pc hiera.ProviderContext, key string) dgo.Value {
+	pv := pc.Option(`path`)
+	if pv == nil {
+		panic(api.MissingRequiredOption(`path`))
+	}
+	path := pv.String()
+
+	var data dgo.Map
+	sc, ok := pc.(api.ServerContext)
+	cacheKey := `eyaml::data::` + path
+	if ok {
+		if cv, found := sc.CachedValue(cacheKey); found {
+			data = cv.(dgo.Map)
+		}
+	}
+	if data == nil {
+		data = YamlData(pc)
+		if ok {
+			sc.Cache(cacheKey, data)
+		}
+	}
+
+	v := data.Get(key)
+	if v == nil {
+		return nil
+	}
+	d := &eyamlDecryptor{pc: pc}
+	return d.decrypt(v)
+}
+
+type eyamlDecryptor struct {
+	pc   hiera.ProviderContext
+	keys *eyamlKeys
+}
+
+// eyamlKeys are the parsed keys that decrypt eyaml blocks
+type eyamlKeys struct {
+	cert *x509.Certificate
+	pkey crypto.PrivateKey
+}
+
+func (d *eyamlDecryptor) decrypt(v dgo.Value) dgo.Value {
+	switch v := v.(type) {
+	case dgo.String:
+		s := v.GoString()
+		if !encPattern.MatchString(s) {
+			return v
+		}
+		return vf.Sensitive(encPattern.ReplaceAllStringFunc(s, d.decryptBlock))
+	case dgo.Map:
+		m := vf.MapWithCapacity(v.Len())
+		v.EachEntry(func(e dgo.MapEntry) { m.Put(e.Key(), d.decrypt(e.Value())) })
+		return m
+	case dgo.Array:
+		return v.Map(func(e dgo.Value) interface{} { return d.decrypt(e) })
+	default:
+		return v
+	}
+}
+
+func (d *eyamlDecryptor) decryptBlock(block string) string {
+	groups := encPattern.FindStringSubmatch(block)
+	if groups[1] != `PKCS7` {
+		panic(fmt.Errorf(`eyaml encryption method '%s' is not supported`, groups[1]))
+	}
+	der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(groups[2]), ``))
+	if err != nil {
+		panic(fmt.Errorf(`unable to decode eyaml block: %s`, err.Error()))
+	}
+	p7, err := pkcs7.Parse(der)
+	if err != nil {
+		panic(fmt.Errorf(`unable to parse eyaml block: %s`, err.Error()))
+	}
+	d.loadKeys()
+	bs, err := p7.Decrypt(d.keys.cert, d.keys.pkey)
+	if err != nil {
+		panic(fmt.Errorf(`unable to decrypt eyaml block: %s`, err.Error()))
+	}
+	return string(bs)
+}
+
+// loadKeys loads the keys appointed by the key options. The parsed keys are cached by the paths of their files so
+// that they are parsed only once.
+func (d *eyamlDecryptor) loadKeys() {
+	if d.keys != nil {
+		return
+	}
+	publicKey := keyOption(d.pc, EyamlPublicKey)
+	privateKey := keyOption(d.pc, EyamlPrivateKey)
+
+	sc, ok := d.pc.(api.ServerContext)
+	cacheKey := `eyaml::keys::` + publicKey + `::` + privateKey
+	if ok {
+		if cv, found := sc.CachedValue(cacheKey); found {
+			d.keys = cv.(dgo.Native).GoValue().(*eyamlKeys)
+			return
+		}
+	}
+	d.keys = parseEyamlKeys(publicKey, privateKey)
+	if ok {
+		sc.Cache(cacheKey, vf.Value(d.keys))
+	}
+}
+
+func parseEyamlKeys(publicKey, privateKey string) *eyamlKeys {
+	certBlock := readPEM(EyamlPublicKey, publicKey)
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		panic(fmt.Errorf(`unable to parse %s: %s`, EyamlPublicKey, err.Error()))
+	}
+
+	keyBlock := readPEM(EyamlPrivateKey, privateKey)
+	var pkey crypto.PrivateKey
+	if keyBlock.Type == `RSA PRIVATE KEY` {
+		pkey, err = x509.ParsePKCS1PrivateKey(keyBlock.Bytes)
+	} else {
+		pkey, err = x509.ParsePKCS8PrivateKey(keyBlock.Bytes)
+	}
+	if err != nil {
+		panic(fmt.Errorf(`unable to parse %s: %s`, EyamlPrivateKey, err.Error()))
+	}
+	return &eyamlKeys{cert: cert, pkey: pkey}
+}
+
+// keyOption returns the path appointed by the given key option
+func keyOption(pc hiera.ProviderContext, option string) string {
+	ov := pc.Option(option)
+	if ov == nil {
+		panic(api.MissingRequiredOption(option))
+	}
+	return ov.String()
+}
+
+// readPEM reads the first PEM block from the given file that is appointed by the given option
+func readPEM(option, path string) *pem.Block {
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		panic(fmt.Errorf(`unable to read %s: %s`, option, err.Error()))
+	}
+	block, _ := pem.Decode(bs)
+	if block == nil {
+		panic(fmt.Errorf(`%s '%s' does not contain a PEM encoded block`, option, path))
+	}
+	return block
+}
@@ -0,0 +1,125 @@
+package provider
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/lyraproj/dgo/dgo"
+	"github.com/lyraproj/dgo/vf"
+	"github.com/lyraproj/hierasdk/hiera"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// HclData is a data_hash provider that reads the attributes of an HCL file, such as a Terraform .tfvars file, and
+// returns them as a Map. The attribute expressions are evaluated without variables or functions. Blocks are not
+// supported. The attributes and the keys of each object retain their order.
+func HclData(ctx hiera.ProviderContext) dgo.Map {
+	path, bs := readDataFile(ctx)
+	if bs == nil {
+		return vf.Map()
+	}
+	f, diags := hclsyntax.ParseConfig(bs, path, hcl.InitialPos)
+	if diags.HasErrors() {
+		panic(fmt.Errorf("could not unmarshal %s: %s", path, diags.Error()))
+	}
+	attrs, diags := f.Body.JustAttributes()
+	if diags.HasErrors() {
+		panic(fmt.Errorf("could not unmarshal %s: %s", path, diags.Error()))
+	}
+
+	// Retain the order of the attributes and object keys in the file
+	order := keyOrder{}
+	names := make([]string, 0, len(attrs))
+	for n, a := range attrs {
+		names = append(names, n)
+		order.add(n, a.Range.Start.Byte)
+		if x, ok := a.Expr.(hclsyntax.Expression); ok {
+			order.addObjectKeys(n, x)
+		}
+	}
+	order.sortKeys(``, names)
+
+	data := vf.MapWithCapacity(len(names))
+	for _, n := range names {
+		v, diags := attrs[n].Expr.Value(nil)
+		if diags.HasErrors() {
+			panic(fmt.Errorf("could not evaluate %s: %s", path, diags.Error()))
+		}
+		data.Put(n, order.ctyValue(n, v))
+	}
+	return data
+}
+
+// add records the given position of the key at the given dotted path unless the key appears earlier
+func (o keyOrder) add(path string, pos int) {
+	if p, ok := o[path]; !ok || pos < p {
+		o[path] = pos
+	}
+}
+
+// addObjectKeys records the positions of the keys of the object constructors in the given expression, which is found
+// at the given dotted path
+func (o keyOrder) addObjectKeys(path string, x hclsyntax.Expression) {
+	switch x := x.(type) {
+	case *hclsyntax.ObjectConsExpr:
+		for _, item := range x.Items {
+			kv, diags := item.KeyExpr.Value(nil)
+			if diags.HasErrors() || kv.IsNull() || kv.Type() != cty.String {
+				continue
+			}
+			kp := joinPath(path, kv.AsString())
+			o.add(kp, item.KeyExpr.Range().Start.Byte)
+			o.addObjectKeys(kp, item.ValueExpr)
+		}
+	case *hclsyntax.TupleConsExpr:
+		for _, e := range x.Exprs {
+			o.addObjectKeys(path, e)
+		}
+	}
+}
+
+// ctyValue converts the given value, found at the given dotted path, into a dgo.Value where the entries of maps are in
+// the order that their keys appear in the document
+func (o keyOrder) ctyValue(path string, v cty.Value) dgo.Value {
+	if v.IsNull() {
+		return vf.Nil
+	}
+	t := v.Type()
+	switch {
+	case t == cty.String:
+		return vf.String(v.AsString())
+	case t == cty.Number:
+		bf := v.AsBigFloat()
+		if i, acc := bf.Int64(); acc == 0 {
+			return vf.Integer(i)
+		}
+		f, _ := bf.Float64()
+		return vf.Float(f)
+	case t == cty.Bool:
+		return vf.Boolean(v.True())
+	case t.IsListType() || t.IsSetType() || t.IsTupleType():
+		a := vf.ArrayWithCapacity(v.LengthInt())
+		for it := v.ElementIterator(); it.Next(); {
+			_, e := it.Element()
+			a.Add(o.ctyValue(path, e))
+		}
+		return a
+	case t.IsMapType() || t.IsObjectType():
+		es := make(map[string]cty.Value, v.LengthInt())
+		keys := make([]string, 0, len(es))
+		for it := v.ElementIterator(); it.Next(); {
+			k, e := it.Element()
+			es[k.AsString()] = e
+			keys = append(keys, k.AsString())
+		}
+		o.sortKeys(path, keys)
+		m := vf.MapWithCapacity(len(keys))
+		for _, k := range keys {
+			m.Put(k, o.ctyValue(joinPath(path, k), es[k]))
+		}
+		return m
+	default:
+		panic(fmt.Errorf("unable to convert HCL value of type %s", t.FriendlyName()))
+	}
+}
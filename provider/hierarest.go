@@ -0,0 +1,84 @@
+package provider
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/lyraproj/dgo/dgo"
+	"github.com/lyraproj/hiera/api"
+	"github.com/lyraproj/hierasdk/hiera"
+)
+
+// HieraRestBaseURL is the option that declares the base URL of the hieraserver used by HieraRestLookupKey
+const HieraRestBaseURL = `base_url`
+
+// HieraRestForwardScope is the option that declares the names of the scope variables that HieraRestLookupKey
+// forwards to the hieraserver
+const HieraRestForwardScope = `forward_scope`
+
+// deepMergeParameters are the deep merge options that have a corresponding hieraserver query parameter
+var deepMergeParameters = []string{`knockout_prefix`, `sort_merged_arrays`, `merge_hash_arrays`}
+
+// HieraRestLookupKey is a LookupKey function that delegates the lookup to the /lookup/{key} endpoint of another
+// hieraserver. The server is appointed by the "base_url" option or, when that option is missing, by the uri
+// location of the hierarchy entry. The options used by HTTPLookupKey to control headers, TLS, and timeouts apply
+// here too.
+//
+// The merge strategy of the current lookup is passed on to the server so that the values found in the levels of
+// the remote hierarchy are merged in the same way as the local ones. The scope variables named in the
+// "forward_scope" option are passed as variables. The key is not found when the server responds with 404 Not Found.
+func HieraRestLookupKey(pc hiera.ProviderContext, key string) dgo.Value {
+	base := stringOption(pc, HieraRestBaseURL)
+	if base == `` {
+		if base = stringOption(pc, `uri`); base == `` {
+			panic(api.MissingRequiredOption(HieraRestBaseURL))
+		}
+	}
+	q := url.Values{}
+	if sc, ok := pc.(api.ServerContext); ok {
+		ic := sc.Invocation()
+		addMergeParameters(ic.MergeStrategy(), q)
+		addScopeParameters(pc, ic.Scope(), q)
+	}
+	u := strings.TrimSuffix(base, `/`) + `/lookup/` + url.PathEscape(key)
+	if len(q) > 0 {
+		u += `?` + q.Encode()
+	}
+	return httpGet(pc, u)
+}
+
+func addMergeParameters(ms api.MergeStrategy, q url.Values) {
+	if ms == nil || ms.Name() == `first` {
+		return
+	}
+	q.Set(`merge`, ms.Name())
+	if opts := ms.Options(); opts != nil {
+		for _, p := range deepMergeParameters {
+			if v := opts.Get(p); v != nil {
+				q.Set(p, v.String())
+			}
+		}
+	}
+}
+
+// addScopeParameters adds a "var" parameter for each forwarded scope variable. The values are encoded so that the
+// server reads them back as values of the same type.
+func addScopeParameters(pc hiera.ProviderContext, scope dgo.Keyed, q url.Values) {
+	var names []string
+	switch fv := pc.Option(HieraRestForwardScope).(type) {
+	case dgo.String:
+		names = []string{fv.GoString()}
+	case dgo.Array:
+		fv.EachWithIndex(func(n dgo.Value, _ int) { names = append(names, n.String()) })
+	}
+	for _, n := range names {
+		var v dgo.Value
+		if scope != nil {
+			v = scope.Get(n)
+		}
+		if v == nil {
+			continue
+		}
+		q.Add(`var`, n+`=`+api.EncodeValue(v))
+	}
+}
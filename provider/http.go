@@ -0,0 +1,310 @@
+package provider
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lyraproj/dgo/dgo"
+	"github.com/lyraproj/dgo/streamer"
+	"github.com/lyraproj/dgo/vf"
+	"github.com/lyraproj/dgoyaml/yaml"
+	"github.com/lyraproj/hiera/api"
+	"github.com/lyraproj/hierasdk/hiera"
+)
+
+// HTTPHeaders is the option that declares a hash of additional headers to send with each request
+const HTTPHeaders = `headers`
+
+// HTTPBearerToken is the option that declares a token to send in an "Authorization: Bearer" header
+const HTTPBearerToken = `bearer_token`
+
+// HTTPCAFile is the option that appoints a file with PEM encoded certificates that are used to verify the server
+const HTTPCAFile = `ca_file`
+
+// HTTPClientCert is the option that appoints a file with a PEM encoded client certificate. It must be used together
+// with HTTPClientKey
+const HTTPClientCert = `client_cert`
+
+// HTTPClientKey is the option that appoints a file with the PEM encoded private key of the client certificate
+const HTTPClientKey = `client_key`
+
+// HTTPTimeout is the option that declares the request timeout. The timeout is either a duration string such as
+// "500ms" or "10s" or an integer number of seconds. The default is 30 seconds.
+const HTTPTimeout = `timeout`
+
+// HTTPCacheTTL is the option that declares how long responses are cached. The duration is either a duration string
+// such as "5m" or an integer number of seconds. Responses are cached according to the max-age of their Cache-Control
+// header when this option isn't given, and until the session is reloaded when they have neither this option nor a
+// max-age. Responses are never cached when the duration is zero or when their Cache-Control header contains no-store.
+const HTTPCacheTTL = `cache_ttl`
+
+// HTTPKeyPlaceholder is replaced with the key in the uri used by HTTPLookupKey
+const HTTPKeyPlaceholder = `__KEY__`
+
+const defaultHTTPTimeout = 30 * time.Second
+
+// HTTPDataHash is a data_hash provider that performs a GET request using the uri appointed by the "uri" option and
+// returns the response as a Map. The response is decoded as JSON or YAML depending on its Content-Type. An empty
+// Map is returned when the server responds with 404 Not Found.
+func HTTPDataHash(pc hiera.ProviderContext) dgo.Map {
+	u := httpURI(pc)
+	v := httpGet(pc, u)
+	if v == nil {
+		return vf.Map()
+	}
+	if data, ok := v.(dgo.Map); ok {
+		return data
+	}
+	panic(fmt.Errorf(`response from %s does not contain a hash`, u))
+}
+
+// HTTPLookupKey is a LookupKey function that performs a GET request using the uri appointed by the "uri" option
+// where each occurrence of "__KEY__" is replaced by the key. The key is appended to the path of the uri when it
+// has no such placeholder. The response is decoded as JSON or YAML depending on its Content-Type. A text/plain
+// response is returned as a string. The key is not found when the server responds with 404 Not Found.
+func HTTPLookupKey(pc hiera.ProviderContext, key string) dgo.Value {
+	u := httpURI(pc)
+	ek := url.PathEscape(key)
+	if strings.Contains(u, HTTPKeyPlaceholder) {
+		u = strings.ReplaceAll(u, HTTPKeyPlaceholder, ek)
+	} else {
+		u = strings.TrimSuffix(u, `/`) + `/` + ek
+	}
+	return httpGet(pc, u)
+}
+
+func httpURI(pc hiera.ProviderContext) string {
+	uv := pc.Option(`uri`)
+	if uv == nil {
+		panic(api.MissingRequiredOption(`uri`))
+	}
+	return uv.String()
+}
+
+// cachedResponse is a decoded response that is cached until it expires. A response with a zero expiry time is cached
+// until the session is reloaded.
+type cachedResponse struct {
+	value   dgo.Value
+	expires time.Time
+}
+
+// httpGet returns the decoded response from a GET request to the given uri or nil if the server responds with
+// 404 Not Found. Responses are cached by uri, headers, and bearer token for the duration given by the cache_ttl
+// option or the Cache-Control header of the response, or until the session is reloaded when neither applies.
+func httpGet(pc hiera.ProviderContext, u string) dgo.Value {
+	sc, ok := pc.(api.ServerContext)
+	cacheKey := httpCacheKey(pc, u)
+	if ok {
+		if cv, found := sc.CachedValue(cacheKey); found {
+			if cr := cv.(dgo.Native).GoValue().(*cachedResponse); cr.expires.IsZero() || time.Now().Before(cr.expires) {
+				return cr.value
+			}
+		}
+	}
+	v, header := httpRequest(pc, u)
+	if ok {
+		if ttl, cache := httpCacheDuration(pc, header); cache {
+			cr := &cachedResponse{value: v}
+			if ttl > 0 {
+				cr.expires = time.Now().Add(ttl)
+			}
+			sc.Cache(cacheKey, vf.Value(cr))
+		}
+	}
+	return v
+}
+
+// httpCacheKey returns the key of the cached response of a request to the given uri. The key includes the headers
+// and a hash of the bearer token since they may change the response.
+func httpCacheKey(pc hiera.ProviderContext, u string) string {
+	b := strings.Builder{}
+	b.WriteString(`http::`)
+	b.WriteString(u)
+	if hm, ok := pc.Option(HTTPHeaders).(dgo.Map); ok {
+		hs := make([]string, 0, hm.Len())
+		hm.EachEntry(func(e dgo.MapEntry) {
+			hs = append(hs, http.CanonicalHeaderKey(e.Key().String())+`: `+e.Value().String())
+		})
+		sort.Strings(hs)
+		for _, h := range hs {
+			b.WriteString("\n")
+			b.WriteString(h)
+		}
+	}
+	if tv := pc.Option(HTTPBearerToken); tv != nil {
+		b.WriteString("\nBearer ")
+		b.WriteString(fmt.Sprintf(`%x`, sha256.Sum256([]byte(tv.String()))))
+	}
+	return b.String()
+}
+
+// httpCacheDuration returns the duration that a response with the given header is cached according to the cache_ttl
+// option and the Cache-Control header, and whether it is cached at all. A response that is cached with a zero
+// duration is cached until the session is reloaded.
+func httpCacheDuration(pc hiera.ProviderContext, header http.Header) (time.Duration, bool) {
+	maxAge := time.Duration(-1)
+	for _, d := range strings.Split(header.Get(`Cache-Control`), `,`) {
+		d = strings.ToLower(strings.TrimSpace(d))
+		switch {
+		case d == `no-store`:
+			return 0, false
+		case strings.HasPrefix(d, `max-age=`):
+			if secs, err := strconv.Atoi(d[len(`max-age=`):]); err == nil {
+				maxAge = time.Duration(secs) * time.Second
+			}
+		}
+	}
+	if pc.Option(HTTPCacheTTL) != nil {
+		ttl := durationOption(pc, HTTPCacheTTL, 0)
+		return ttl, ttl > 0
+	}
+	if maxAge >= 0 {
+		return maxAge, maxAge > 0
+	}
+	return 0, true
+}
+
+func httpRequest(pc hiera.ProviderContext, u string) (dgo.Value, http.Header) {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		panic(err)
+	}
+	req.Header.Set(`Accept`, `application/json, application/yaml;q=0.9, text/plain;q=0.8`)
+	if hm, ok := pc.Option(HTTPHeaders).(dgo.Map); ok {
+		hm.EachEntry(func(e dgo.MapEntry) { req.Header.Set(e.Key().String(), e.Value().String()) })
+	}
+	if tv := pc.Option(HTTPBearerToken); tv != nil {
+		req.Header.Set(`Authorization`, `Bearer `+tv.String())
+	}
+
+	client := &http.Client{Transport: httpTransport(pc), Timeout: httpTimeout(pc)}
+	resp, err := client.Do(req)
+	if err != nil {
+		panic(err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	bs, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		panic(err)
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, resp.Header
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		if len(bs) > 0 {
+			panic(fmt.Errorf(`GET %s %s: %s`, u, resp.Status, strings.TrimSpace(string(bs))))
+		}
+		panic(fmt.Errorf(`GET %s %s`, u, resp.Status))
+	}
+	return decodeResponse(u, resp.Header.Get(`Content-Type`), bs), resp.Header
+}
+
+func decodeResponse(u, contentType string, bs []byte) dgo.Value {
+	mt, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mt == `application/json` || strings.HasSuffix(mt, `+json`):
+		return streamer.UnmarshalJSON(bs, nil)
+	case mt == `application/yaml` || mt == `application/x-yaml` || mt == `text/yaml` || mt == `text/x-yaml` ||
+		strings.HasSuffix(mt, `+yaml`):
+		v, err := yaml.Unmarshal(bs)
+		if err != nil {
+			panic(fmt.Errorf(`could not unmarshal response from %s: %s`, u, err.Error()))
+		}
+		return v
+	case mt == `text/plain`:
+		return vf.String(string(bs))
+	}
+	panic(fmt.Errorf(`response from %s has unsupported content type '%s'`, u, contentType))
+}
+
+func httpTimeout(pc hiera.ProviderContext) time.Duration {
+	return durationOption(pc, HTTPTimeout, defaultHTTPTimeout)
+}
+
+// durationOption returns the duration declared by the option with the given name as either a duration string or an
+// integer number of seconds, or the given default when the option isn't given
+func durationOption(pc hiera.ProviderContext, name string, dflt time.Duration) time.Duration {
+	switch tv := pc.Option(name).(type) {
+	case nil:
+		return dflt
+	case dgo.Integer:
+		return time.Duration(tv.GoInt()) * time.Second
+	case dgo.String:
+		d, err := time.ParseDuration(tv.GoString())
+		if err != nil {
+			panic(fmt.Errorf(`invalid %s option: %s`, name, err.Error()))
+		}
+		return d
+	default:
+		panic(fmt.Errorf(`invalid %s option: %s`, name, tv))
+	}
+}
+
+// httpTransport returns the transport to use with the CA file and client certificate given by the options. The
+// transport is cached by the session so that connections are reused until the session is reloaded.
+func httpTransport(pc hiera.ProviderContext) http.RoundTripper {
+	caFile := stringOption(pc, HTTPCAFile)
+	certFile := stringOption(pc, HTTPClientCert)
+	keyFile := stringOption(pc, HTTPClientKey)
+	if caFile == `` && certFile == `` && keyFile == `` {
+		return http.DefaultTransport
+	}
+	sc, ok := pc.(api.ServerContext)
+	cacheKey := `http-transport::` + caFile + "\n" + certFile + "\n" + keyFile
+	if ok {
+		if cv, found := sc.CachedValue(cacheKey); found {
+			return cv.(dgo.Native).GoValue().(*http.Transport)
+		}
+	}
+
+	tc := &tls.Config{}
+	if caFile != `` {
+		bs, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			panic(fmt.Errorf(`unable to read %s: %s`, HTTPCAFile, err.Error()))
+		}
+		tc.RootCAs = x509.NewCertPool()
+		if !tc.RootCAs.AppendCertsFromPEM(bs) {
+			panic(fmt.Errorf(`%s '%s' does not contain any PEM encoded certificates`, HTTPCAFile, caFile))
+		}
+	}
+	if certFile != `` || keyFile != `` {
+		if certFile == `` {
+			panic(api.MissingRequiredOption(HTTPClientCert))
+		}
+		if keyFile == `` {
+			panic(api.MissingRequiredOption(HTTPClientKey))
+		}
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			panic(fmt.Errorf(`unable to load client certificate: %s`, err.Error()))
+		}
+		tc.Certificates = []tls.Certificate{cert}
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.TLSClientConfig = tc
+	if ok {
+		sc.Cache(cacheKey, vf.Value(t))
+	}
+	return t
+}
+
+func stringOption(pc hiera.ProviderContext, name string) string {
+	if v := pc.Option(name); v != nil {
+		return v.String()
+	}
+	return ``
+}
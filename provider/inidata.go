@@ -0,0 +1,43 @@
+package provider
+
+import (
+	"fmt"
+
+	"github.com/lyraproj/dgo/dgo"
+	"github.com/lyraproj/dgo/vf"
+	"github.com/lyraproj/hierasdk/hiera"
+	"gopkg.in/ini.v1"
+)
+
+// IniData is a data_hash provider that reads an INI file and returns it as a Map. Keys that precede the first
+// section are entered directly into the Map. All other sections are entered as a Map keyed by the section name.
+//
+// INI files are untyped so values that can be parsed as integers, floats, booleans ("true" or "false"), or RFC3339
+// timestamps are converted to the corresponding type. All other values are strings.
+func IniData(ctx hiera.ProviderContext) dgo.Map {
+	path, bs := readDataFile(ctx)
+	if bs == nil {
+		return vf.Map()
+	}
+	f, err := ini.Load(bs)
+	if err != nil {
+		panic(fmt.Errorf("could not unmarshal %s: %s", path, err.Error()))
+	}
+	data := vf.MapWithCapacity(len(f.Sections()))
+	for _, s := range f.Sections() {
+		if s.Name() == ini.DefaultSection {
+			iniKeys(s, data)
+		} else if len(s.Keys()) > 0 {
+			sm := vf.MapWithCapacity(len(s.Keys()))
+			iniKeys(s, sm)
+			data.Put(s.Name(), sm)
+		}
+	}
+	return data
+}
+
+func iniKeys(s *ini.Section, m dgo.Map) {
+	for _, k := range s.Keys() {
+		m.Put(k.Name(), scalarValue(k.Value()))
+	}
+}
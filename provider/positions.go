@@ -0,0 +1,156 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/lyraproj/hiera/api"
+	"gopkg.in/yaml.v3"
+)
+
+// YamlPositions reads the YAML file at the given path and returns the position of each key in it. The positions
+// are keyed by dot separated key paths where array elements are denoted by their index. An empty map is returned
+// if the file doesn't exist.
+func YamlPositions(path string) map[string]*api.Position {
+	bs := readPositionSource(path)
+	ps := make(map[string]*api.Position)
+	if len(bs) == 0 {
+		return ps
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(bs, &doc); err != nil {
+		panic(fmt.Errorf("could not unmarshal %s: %s", path, err.Error()))
+	}
+	if len(doc.Content) > 0 {
+		yamlPositions(path, ``, doc.Content[0], ps)
+	}
+	return ps
+}
+
+func yamlPositions(path, prefix string, n *yaml.Node, ps map[string]*api.Position) {
+	switch n.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k := n.Content[i]
+			kp := joinPath(prefix, k.Value)
+			ps[kp] = &api.Position{File: path, Line: k.Line, Column: k.Column}
+			yamlPositions(path, kp, n.Content[i+1], ps)
+		}
+	case yaml.SequenceNode:
+		for i, e := range n.Content {
+			ep := joinPath(prefix, strconv.Itoa(i))
+			ps[ep] = &api.Position{File: path, Line: e.Line, Column: e.Column}
+			yamlPositions(path, ep, e, ps)
+		}
+	}
+}
+
+// JSONPositions reads the JSON file at the given path and returns the position of each key in it. The positions
+// are keyed by dot separated key paths where array elements are denoted by their index. An empty map is returned
+// if the file doesn't exist.
+func JSONPositions(path string) map[string]*api.Position {
+	bs := readPositionSource(path)
+	ps := make(map[string]*api.Position)
+	if len(bs) == 0 {
+		return ps
+	}
+	jp := &jsonPositions{path: path, source: bs, lineStarts: lineStarts(bs), dec: json.NewDecoder(bytes.NewReader(bs)), ps: ps}
+	if err := jp.value(``); err != nil {
+		panic(fmt.Errorf("could not unmarshal %s: %s", path, err.Error()))
+	}
+	return ps
+}
+
+type jsonPositions struct {
+	path       string
+	source     []byte
+	lineStarts []int
+	dec        *json.Decoder
+	ps         map[string]*api.Position
+}
+
+// value consumes the next JSON value from the decoder and records the positions of the keys and elements in it
+func (jp *jsonPositions) value(prefix string) error {
+	t, err := jp.dec.Token()
+	if err != nil {
+		return err
+	}
+	switch t {
+	case json.Delim('{'):
+		for jp.dec.More() {
+			start := jp.tokenStart()
+			kt, err := jp.dec.Token()
+			if err != nil {
+				return err
+			}
+			kp := joinPath(prefix, fmt.Sprint(kt))
+			jp.ps[kp] = jp.position(start)
+			if err = jp.value(kp); err != nil {
+				return err
+			}
+		}
+		_, err = jp.dec.Token()
+	case json.Delim('['):
+		for i := 0; jp.dec.More(); i++ {
+			ep := joinPath(prefix, strconv.Itoa(i))
+			jp.ps[ep] = jp.position(jp.tokenStart())
+			if err = jp.value(ep); err != nil {
+				return err
+			}
+		}
+		_, err = jp.dec.Token()
+	}
+	return err
+}
+
+// tokenStart returns the offset of the start of the next token
+func (jp *jsonPositions) tokenStart() int {
+	i := int(jp.dec.InputOffset())
+	for ; i < len(jp.source); i++ {
+		switch jp.source[i] {
+		case ' ', '\t', '\r', '\n', ',', ':':
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+func (jp *jsonPositions) position(offset int) *api.Position {
+	line := sort.Search(len(jp.lineStarts), func(i int) bool { return jp.lineStarts[i] > offset })
+	return &api.Position{File: jp.path, Line: line, Column: offset - jp.lineStarts[line-1] + 1}
+}
+
+// lineStarts returns the offsets of the first character of each line in the given source
+func lineStarts(bs []byte) []int {
+	ls := []int{0}
+	for i, b := range bs {
+		if b == '\n' {
+			ls = append(ls, i+1)
+		}
+	}
+	return ls
+}
+
+func readPositionSource(path string) []byte {
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		panic(fmt.Errorf("could not read %s: %s", path, err.Error()))
+	}
+	return bs
+}
+
+func joinPath(prefix, key string) string {
+	if prefix == `` {
+		return key
+	}
+	return prefix + `.` + key
+}
@@ -0,0 +1,41 @@
+package provider
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lyraproj/dgo/dgo"
+	"github.com/lyraproj/dgo/vf"
+)
+
+// scalarValue converts a string from an untyped source into an integer, float, boolean ("true" or "false"), or
+// RFC3339 timestamp when possible. A string is only converted into a number when the number is formatted as that
+// exact string, so that strings such as the zip code "02134" or the version "1.10" are retained. All other strings
+// are returned unaltered.
+func scalarValue(s string) dgo.Value {
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil && strconv.FormatInt(i, 10) == s {
+		return vf.Integer(i)
+	}
+	if strings.ContainsAny(s, `0123456789`) {
+		// ParseFloat also accepts strings like "Inf" and "NaN"
+		if f, err := strconv.ParseFloat(s, 64); err == nil && formatsAs(f, s) {
+			return vf.Float(f)
+		}
+	}
+	switch strings.ToLower(s) {
+	case `true`:
+		return vf.True
+	case `false`:
+		return vf.False
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return vf.Time(t)
+	}
+	return vf.String(s)
+}
+
+// formatsAs returns true if the given float is formatted as the given string using either decimal or exponent notation
+func formatsAs(f float64, s string) bool {
+	return strconv.FormatFloat(f, 'f', -1, 64) == s || strconv.FormatFloat(f, 'g', -1, 64) == s
+}
@@ -0,0 +1,380 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/lyraproj/dgo/dgo"
+	"github.com/lyraproj/dgo/vf"
+	"github.com/lyraproj/dgoyaml/yaml"
+	"github.com/lyraproj/hiera/api"
+	"github.com/lyraproj/hierasdk/hiera"
+)
+
+// SopsAgeKeyFile is the option that appoints the file containing the age identities used by SopsData
+const SopsAgeKeyFile = `age_key_file`
+
+const ageHeader = `age-encryption.org/v1`
+
+var sopsValuePattern = regexp.MustCompile(`\AENC\[AES256_GCM,data:(.*),iv:(.*),tag:(.*),type:(.*)\]\z`)
+
+// SopsData is a data_hash provider that reads an encrypted YAML or JSON hash from a file and returns it as a
+// decrypted Map. The file can either be encrypted in its entirety using age, or be a sops file where each value
+// is encrypted using a data key that in turn is encrypted for one or more age recipients. The identities that
+// are used for the decryption are read from the file appointed by the "age_key_file" option.
+//
+// All decrypted values are returned as Sensitive values. The message authentication code of a sops file is verified
+// against the decrypted values, and a sops file with a plaintext value is rejected unless the key of that value is
+// declared as unencrypted by the sops metadata.
+func SopsData(ctx hiera.ProviderContext) dgo.Map {
+	pv := ctx.Option(`path`)
+	if pv == nil {
+		panic(api.MissingRequiredOption(`path`))
+	}
+	path := pv.String()
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return vf.Map()
+		}
+		panic(fmt.Errorf("could not read %s: %s", path, err.Error()))
+	}
+
+	encrypted := isAgeEncrypted(bs)
+	if encrypted {
+		if bs, err = ageDecrypt(bytes.NewReader(bs), ageIdentities(ctx)); err != nil {
+			panic(ageDecryptError(ctx, path, err))
+		}
+	}
+	v, err := yaml.Unmarshal(bs)
+	if err != nil {
+		panic(fmt.Errorf("could not unmarshal %s: %s", path, err.Error()))
+	}
+	data, ok := v.(dgo.Map)
+	if !ok {
+		panic(api.YamlNotHash(path))
+	}
+	if md, ok := data.Get(`sops`).(dgo.Map); ok {
+		return sopsDecrypt(ctx, path, data, md)
+	}
+	if !encrypted {
+		panic(fmt.Errorf("%s is neither encrypted using age nor a sops file", path))
+	}
+	return sensitiveValues(data).(dgo.Map)
+}
+
+// sensitiveValues returns the given value with all values that aren't hashes or arrays wrapped as Sensitive values
+func sensitiveValues(v dgo.Value) dgo.Value {
+	switch v := v.(type) {
+	case dgo.Map:
+		m := vf.MapWithCapacity(v.Len())
+		v.EachEntry(func(e dgo.MapEntry) {
+			m.Put(e.Key(), sensitiveValues(e.Value()))
+		})
+		return m
+	case dgo.Array:
+		return v.Map(func(e dgo.Value) interface{} { return sensitiveValues(e) })
+	default:
+		return vf.Sensitive(v)
+	}
+}
+
+func isAgeEncrypted(bs []byte) bool {
+	return bytes.HasPrefix(bs, []byte(ageHeader)) || bytes.HasPrefix(bytes.TrimSpace(bs), []byte(armor.Header))
+}
+
+// ageDecrypt decrypts the age encrypted content of the given reader using the given identities. The content may be
+// armored.
+func ageDecrypt(r io.Reader, ids []age.Identity) ([]byte, error) {
+	br := bufio.NewReader(r)
+	if start, _ := br.Peek(len(armor.Header)); string(start) == armor.Header {
+		r = armor.NewReader(br)
+	} else {
+		r = br
+	}
+	dr, err := age.Decrypt(r, ids...)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(dr)
+}
+
+func ageDecryptError(ctx hiera.ProviderContext, path string, err error) error {
+	if _, ok := err.(*age.NoIdentityMatchError); ok {
+		return fmt.Errorf("none of the identities in %s '%s' can decrypt %s", SopsAgeKeyFile, ctx.Option(SopsAgeKeyFile), path)
+	}
+	return fmt.Errorf("unable to decrypt %s: %s", path, err.Error())
+}
+
+func ageIdentities(ctx hiera.ProviderContext) []age.Identity {
+	kv := ctx.Option(SopsAgeKeyFile)
+	if kv == nil {
+		panic(api.MissingRequiredOption(SopsAgeKeyFile))
+	}
+	f, err := os.Open(kv.String())
+	if err != nil {
+		panic(fmt.Errorf("unable to read %s: %s", SopsAgeKeyFile, err.Error()))
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	ids, err := age.ParseIdentities(f)
+	if err != nil {
+		panic(fmt.Errorf("unable to parse %s '%s': %s", SopsAgeKeyFile, kv, err.Error()))
+	}
+	return ids
+}
+
+// sopsDecrypt decrypts the data key of the given sops metadata and uses it to decrypt the values of the data
+func sopsDecrypt(ctx hiera.ProviderContext, path string, data, md dgo.Map) dgo.Map {
+	stanzas, ok := md.Get(`age`).(dgo.Array)
+	if !ok || stanzas.Len() == 0 {
+		panic(fmt.Errorf("sops file %s has no age recipients", path))
+	}
+	ids := ageIdentities(ctx)
+	var key []byte
+	var err error = &age.NoIdentityMatchError{}
+	stanzas.EachWithIndex(func(s dgo.Value, _ int) {
+		if key != nil {
+			return
+		}
+		if sm, ok := s.(dgo.Map); ok {
+			if enc, ok := sm.Get(`enc`).(dgo.String); ok {
+				key, err = ageDecrypt(strings.NewReader(enc.GoString()), ids)
+			}
+		}
+	})
+	if key == nil {
+		panic(ageDecryptError(ctx, path, err))
+	}
+
+	f := newSopsFile(path, key, md)
+	result := vf.MapWithCapacity(data.Len() - 1)
+	data.EachEntry(func(e dgo.MapEntry) {
+		k := e.Key().String()
+		if k != `sops` {
+			result.Put(k, f.value(e.Value(), []string{k}))
+		}
+	})
+	f.verifyMac(md)
+	return result
+}
+
+// sopsFile decrypts the values of a sops file and computes their message authentication code
+type sopsFile struct {
+	path              string
+	key               []byte
+	mac               hash.Hash
+	macOnlyEncrypted  bool
+	unencryptedSuffix string
+	encryptedSuffix   string
+	unencryptedRegex  *regexp.Regexp
+	encryptedRegex    *regexp.Regexp
+}
+
+func newSopsFile(path string, key []byte, md dgo.Map) *sopsFile {
+	f := &sopsFile{path: path, key: key, mac: sha512.New()}
+	if b, ok := md.Get(`mac_only_encrypted`).(dgo.Boolean); ok {
+		f.macOnlyEncrypted = b.GoBool()
+	}
+	stringOption := func(name string) string {
+		if s, ok := md.Get(name).(dgo.String); ok {
+			return s.GoString()
+		}
+		return ``
+	}
+	regexpOption := func(name string) *regexp.Regexp {
+		rx := stringOption(name)
+		if rx == `` {
+			return nil
+		}
+		re, err := regexp.Compile(rx)
+		if err != nil {
+			panic(fmt.Errorf("invalid %s in sops file %s: %s", name, path, err.Error()))
+		}
+		return re
+	}
+	f.unencryptedSuffix = stringOption(`unencrypted_suffix`)
+	f.encryptedSuffix = stringOption(`encrypted_suffix`)
+	f.unencryptedRegex = regexpOption(`unencrypted_regex`)
+	f.encryptedRegex = regexpOption(`encrypted_regex`)
+	if f.unencryptedSuffix == `` && f.encryptedSuffix == `` && f.unencryptedRegex == nil && f.encryptedRegex == nil {
+		f.unencryptedSuffix = `_unencrypted`
+	}
+	return f
+}
+
+// isEncrypted returns true if the value at the given key path is encrypted according to the sops metadata. The
+// rules are the same as the ones that sops uses when it encrypts a file.
+func (f *sopsFile) isEncrypted(keyPath []string) bool {
+	encrypted := f.encryptedSuffix == `` && f.encryptedRegex == nil
+	for _, k := range keyPath {
+		switch {
+		case f.unencryptedSuffix != `` && strings.HasSuffix(k, f.unencryptedSuffix),
+			f.unencryptedRegex != nil && f.unencryptedRegex.MatchString(k):
+			return false
+		case f.encryptedSuffix != `` && strings.HasSuffix(k, f.encryptedSuffix),
+			f.encryptedRegex != nil && f.encryptedRegex.MatchString(k):
+			encrypted = true
+		}
+	}
+	return encrypted
+}
+
+// value returns the given value, found at the given key path, with all encrypted values decrypted and wrapped as
+// Sensitive values. All values are added to the message authentication code in the order that they are found.
+func (f *sopsFile) value(v dgo.Value, keyPath []string) dgo.Value {
+	switch v := v.(type) {
+	case dgo.Map:
+		m := vf.MapWithCapacity(v.Len())
+		v.EachEntry(func(e dgo.MapEntry) {
+			m.Put(e.Key(), f.value(e.Value(), append(keyPath[:len(keyPath):len(keyPath)], e.Key().String())))
+		})
+		return m
+	case dgo.Array:
+		return v.Map(func(e dgo.Value) interface{} { return f.value(e, keyPath) })
+	}
+
+	if !f.isEncrypted(keyPath) {
+		f.addToMac(v, false)
+		return v
+	}
+	at := strings.Join(keyPath, `:`)
+	s, ok := v.(dgo.String)
+	if ok && s.GoString() == `` {
+		// sops doesn't encrypt empty strings
+		return v
+	}
+	var groups []string
+	if ok {
+		groups = sopsValuePattern.FindStringSubmatch(s.GoString())
+	}
+	if groups == nil {
+		panic(fmt.Errorf("value at '%s' in sops file %s is not encrypted", at, f.path))
+	}
+	dv, err := sopsDecryptValue(f.key, groups, at+`:`)
+	if err != nil {
+		panic(fmt.Errorf("unable to decrypt value at '%s' in %s: %s", at, f.path, err.Error()))
+	}
+	f.addToMac(dv, true)
+	return vf.Sensitive(dv)
+}
+
+// addToMac adds the given value to the message authentication code unless the value isn't encrypted and the code
+// only includes encrypted values
+func (f *sopsFile) addToMac(v dgo.Value, encrypted bool) {
+	if !encrypted && f.macOnlyEncrypted {
+		return
+	}
+	var s string
+	switch v := v.(type) {
+	case dgo.String:
+		s = v.GoString()
+	case dgo.Integer:
+		s = strconv.FormatInt(v.GoInt(), 10)
+	case dgo.Float:
+		s = strconv.FormatFloat(v.GoFloat(), 'f', -1, 64)
+	case dgo.Boolean:
+		// sops uses the Python representation of booleans
+		s = `False`
+		if v.GoBool() {
+			s = `True`
+		}
+	default:
+		s = v.String()
+	}
+	_, _ = f.mac.Write([]byte(s))
+}
+
+// verifyMac verifies that the message authentication code in the given sops metadata matches the code computed
+// from the values of the file. The code is encrypted using the time of the last modification as additional data.
+func (f *sopsFile) verifyMac(md dgo.Map) {
+	ms, ok := md.Get(`mac`).(dgo.String)
+	if !ok {
+		panic(fmt.Errorf("sops file %s has no message authentication code", f.path))
+	}
+	var lastModified string
+	switch lm := md.Get(`lastmodified`).(type) {
+	case nil:
+	case dgo.Time:
+		lastModified = lm.GoTime().Format(time.RFC3339)
+	default:
+		lastModified = lm.String()
+	}
+	var mac dgo.Value
+	groups := sopsValuePattern.FindStringSubmatch(ms.GoString())
+	err := errors.New(`not an encrypted value`)
+	if groups != nil {
+		mac, err = sopsDecryptValue(f.key, groups, lastModified)
+	}
+	if err != nil {
+		panic(fmt.Errorf("unable to decrypt the message authentication code of %s: %s", f.path, err.Error()))
+	}
+	if mac.String() != fmt.Sprintf(`%X`, f.mac.Sum(nil)) {
+		panic(fmt.Errorf("the message authentication code of %s does not match its values", f.path))
+	}
+}
+
+// sopsDecryptValue decrypts the value with the given groups of the sopsValuePattern using the given key and
+// additional data
+func sopsDecryptValue(key []byte, groups []string, additionalData string) (dgo.Value, error) {
+	var parts [3][]byte
+	for i := range parts {
+		var err error
+		if parts[i], err = base64.StdEncoding.DecodeString(groups[i+1]); err != nil {
+			return nil, err
+		}
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCMWithNonceSize(block, len(parts[1]))
+	if err != nil {
+		return nil, err
+	}
+	plain, err := gcm.Open(nil, parts[1], append(parts[0], parts[2]...), []byte(additionalData))
+	if err != nil {
+		return nil, err
+	}
+	ps := string(plain)
+	var v dgo.Value
+	switch groups[4] {
+	case `str`, `bytes`:
+		v = vf.String(ps)
+	case `int`:
+		var i int64
+		if i, err = strconv.ParseInt(ps, 10, 64); err == nil {
+			v = vf.Integer(i)
+		}
+	case `float`:
+		var f float64
+		if f, err = strconv.ParseFloat(ps, 64); err == nil {
+			v = vf.Float(f)
+		}
+	case `bool`:
+		var b bool
+		if b, err = strconv.ParseBool(ps); err == nil {
+			v = vf.Boolean(b)
+		}
+	default:
+		err = fmt.Errorf("unknown data type '%s'", groups[4])
+	}
+	return v, err
+}
@@ -0,0 +1,117 @@
+package provider
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"sort"
+
+	"github.com/BurntSushi/toml"
+	"github.com/lyraproj/dgo/dgo"
+	"github.com/lyraproj/dgo/vf"
+	"github.com/lyraproj/hiera/api"
+	"github.com/lyraproj/hierasdk/hiera"
+)
+
+// TomlData is a data_hash provider that reads a TOML document from a file and returns it as a Map. Integers,
+// floats, booleans, and date-times retain their TOML types and the keys of each table retain their order.
+func TomlData(ctx hiera.ProviderContext) dgo.Map {
+	path, bs := readDataFile(ctx)
+	if bs == nil {
+		return vf.Map()
+	}
+	var data map[string]interface{}
+	md, err := toml.Decode(string(bs), &data)
+	if err != nil {
+		panic(fmt.Errorf("could not unmarshal %s: %s", path, err.Error()))
+	}
+
+	// Retain the order of the keys in the file
+	keys := md.Keys()
+	order := make(keyOrder, len(keys))
+	for i, k := range keys {
+		ks := k.String()
+		if _, ok := order[ks]; !ok {
+			order[ks] = i
+		}
+	}
+	return order.goMap(``, data)
+}
+
+// keyOrder maps the dotted path of each key in a TOML or HCL document to the position where the key first appears
+type keyOrder map[string]int
+
+// goMap converts the given Go map, found at the given dotted path, into a Map where the entries are in the order
+// that their keys appear in the document
+func (o keyOrder) goMap(path string, m map[string]interface{}) dgo.Map {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	o.sortKeys(path, keys)
+	dm := vf.MapWithCapacity(len(keys))
+	for _, k := range keys {
+		dm.Put(k, o.goValue(joinPath(path, k), m[k]))
+	}
+	return dm
+}
+
+func (o keyOrder) goValue(path string, v interface{}) dgo.Value {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return o.goMap(path, v)
+	case []map[string]interface{}:
+		a := vf.ArrayWithCapacity(len(v))
+		for _, e := range v {
+			a.Add(o.goMap(path, e))
+		}
+		return a
+	case []interface{}:
+		a := vf.ArrayWithCapacity(len(v))
+		for _, e := range v {
+			a.Add(o.goValue(path, e))
+		}
+		return a
+	default:
+		return vf.Value(v)
+	}
+}
+
+// sortKeys sorts the given keys of the table at the given path in the order that they appear in the document
+func (o keyOrder) sortKeys(path string, keys []string) {
+	sort.Slice(keys, func(i, j int) bool {
+		pi, pj := o.position(path, keys[i]), o.position(path, keys[j])
+		if pi != pj {
+			return pi < pj
+		}
+		return keys[i] < keys[j]
+	})
+}
+
+// position returns the position of the given key of the table at the given path. Keys that aren't known are
+// positioned last.
+func (o keyOrder) position(path, key string) int {
+	if p, ok := o[joinPath(path, key)]; ok {
+		return p
+	}
+	return math.MaxInt32
+}
+
+// readDataFile reads the file appointed by the "path" option and returns its path and contents. The returned
+// contents is nil when the file doesn't exist.
+func readDataFile(ctx hiera.ProviderContext) (string, []byte) {
+	pv := ctx.Option(`path`)
+	if pv == nil {
+		panic(api.MissingRequiredOption(`path`))
+	}
+	path := pv.String()
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return path, nil
+		}
+		panic(fmt.Errorf("could not read %s: %s", path, err.Error()))
+	}
+	return path, bs
+}
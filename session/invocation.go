@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/lyraproj/hiera/merge"
+	"github.com/lyraproj/hiera/metrics"
 
 	"github.com/lyraproj/dgo/vf"
 
@@ -31,14 +33,33 @@ const (
 
 type ivContext struct {
 	api.Session
+	caches    *caches
 	nameStack []string
 	scope     dgo.Keyed
 	luOpts    dgo.Map
+	valueType dgo.Type
 	strategy  api.MergeStrategy
 	configs   map[string]api.ResolvedConfig
 	explainer api.Explainer
 	mode      invocationMode
 	redacted  bool
+	sources   *provenanceRecorder
+	supplier  *valueSupplier
+}
+
+// provenanceRecorder records the provenance of the value most recently found for a specific root key
+type provenanceRecorder struct {
+	root  string
+	found *api.Provenance
+}
+
+// valueSupplier records the data provider and location that supplied the last value found during a lookup, and the
+// names of all hierarchy levels that supplied a value, per root key
+type valueSupplier struct {
+	provider api.DataProvider
+	location api.Location
+	key      api.Key
+	levels   map[string][]string
 }
 
 type nestedScope struct {
@@ -46,9 +67,10 @@ type nestedScope struct {
 	scope       dgo.Keyed
 }
 
-func newInvocation(s api.Session, scope dgo.Keyed, explainer api.Explainer) api.Invocation {
+func newInvocation(s api.Session, c *caches, scope dgo.Keyed, explainer api.Explainer) api.Invocation {
 	return &ivContext{
 		Session:   s,
+		caches:    c,
 		nameStack: []string{},
 		scope:     scope,
 		configs:   map[string]api.ResolvedConfig{},
@@ -63,6 +85,16 @@ func (ns *nestedScope) Get(key interface{}) dgo.Value {
 	return ns.parentScope.Get(key)
 }
 
+// SharedCache returns the shared cache of the session as it was when the invocation was created
+func (ic *ivContext) SharedCache() *sync.Map {
+	return ic.caches.shared
+}
+
+// TopProviderCache returns the provider cache of the session as it was when the invocation was created
+func (ic *ivContext) TopProviderCache() *sync.Map {
+	return ic.caches.topProvider
+}
+
 func (ic *ivContext) Config(configPath string, moduleName string) api.ResolvedConfig {
 	sc := ic.SharedCache()
 	if configPath == `` {
@@ -74,7 +106,9 @@ func (ic *ivContext) Config(configPath string, moduleName string) api.ResolvedCo
 	}
 
 	cp := hieraConfigsPrefix + configPath
-	if val, ok := sc.Load(cp); ok {
+	val, ok := sc.Load(cp)
+	metrics.ObserveCache(metrics.ConfigCache, ok)
+	if ok {
 		rc := Resolve(ic, val.(api.Config), moduleName)
 		ic.configs[configPath] = rc
 		return rc
@@ -147,13 +181,35 @@ func (ic *ivContext) extractConversion() (convertToType dgo.Type, convertToArgs
 		ts = ct
 	}
 	if ts != nil {
-		ic.AliasMap().Collect(func(aa dgo.AliasAdder) {
-			convertToType = ic.Dialect().ParseType(aa, ts.(dgo.String))
-		})
+		convertToType = ic.parseType(ts.(dgo.String))
 	}
 	return
 }
 
+// extractValueType returns the type declared using the "type" entry of the current lookup options or nil
+// if no such entry exists.
+func (ic *ivContext) extractValueType() dgo.Type {
+	lo := ic.luOpts
+	if lo == nil {
+		return nil
+	}
+	switch ts := lo.Get(`type`).(type) {
+	case nil:
+		return nil
+	case dgo.String:
+		return ic.parseType(ts)
+	default:
+		panic(fmt.Errorf(`lookup_options "type" must be a string, got %s`, typ.Generic(ts.Type())))
+	}
+}
+
+func (ic *ivContext) parseType(ts dgo.String) (t dgo.Type) {
+	ic.AliasMap().Collect(func(aa dgo.AliasAdder) {
+		t = ic.Dialect().ParseType(aa, ts)
+	})
+	return
+}
+
 func (ic *ivContext) SetMergeStrategy(cliMergeOption dgo.Value, lookupOptions dgo.Map) {
 	var opts dgo.Value
 	if cliMergeOption != nil {
@@ -179,11 +235,21 @@ func (ic *ivContext) SetMergeStrategy(cliMergeOption dgo.Value, lookupOptions dg
 		mergeName = `first`
 	}
 	ic.luOpts = lookupOptions
+	ic.valueType = ic.extractValueType()
 	ic.strategy = merge.GetStrategy(mergeName, mergeOpts)
 }
 
 func (ic *ivContext) LookupAndConvertData(fn func() dgo.Value) dgo.Value {
 	convertToType, convertToArgs := ic.extractConversion()
+	valueType := ic.valueType
+	if valueType != nil {
+		saved := ic.supplier
+		ic.supplier = &valueSupplier{}
+		defer func() {
+			ic.supplier = saved
+		}()
+	}
+	first := ic.strategy.Name() == `first`
 
 	var v dgo.Value
 	if typ.Sensitive.Equals(convertToType) {
@@ -192,23 +258,54 @@ func (ic *ivContext) LookupAndConvertData(fn func() dgo.Value) dgo.Value {
 		v = fn()
 	}
 
+	if v != nil && valueType != nil {
+		ic.assertValueType(valueType, first, v)
+	}
+
 	if v != nil && convertToType != nil {
 		if convertToArgs != nil {
 			v = vf.Arguments(vf.Values(v).WithAll(convertToArgs))
 		}
 		v = vf.New(convertToType, v)
+
+		// The parts of a converted value cannot be attributed to the parts of the found value
+		ic.SetProvenance(ic.TakeProvenance().Collapse())
 	}
 	return v
 }
 
 func (ic *ivContext) MergeHierarchy(key api.Key, pvs []api.DataProvider, merge api.MergeStrategy) dgo.Value {
+	stoppedBy := ``
 	return merge.MergeLookup(pvs, ic, func(pv interface{}) dgo.Value {
 		pr := pv.(api.DataProvider)
-		return ic.MergeLocations(key, pr, merge)
+		if stoppedBy != `` {
+			return ic.skipProvider(pr, fmt.Sprintf(`Not merged due to merge_behavior "%s" in hierarchy level '%s'`,
+				api.MergeBehaviorStop, stoppedBy))
+		}
+		mb := pr.Hierarchy().MergeBehavior()
+		if mb == api.MergeBehaviorSkipPrefix+merge.Name() {
+			return ic.skipProvider(pr, fmt.Sprintf(`Skipped due to merge_behavior "%s"`, mb))
+		}
+		v := ic.MergeLocations(key, pr, merge)
+		if v != nil && mb == api.MergeBehaviorStop {
+			stoppedBy = pr.Hierarchy().Name()
+		}
+		return v
+	})
+}
+
+// skipProvider reports that the given provider was skipped and returns nil
+func (ic *ivContext) skipProvider(dh api.DataProvider, reason string) dgo.Value {
+	return ic.WithDataProvider(dh, func() dgo.Value {
+		ic.ReportText(func() string { return reason })
+		return nil
 	})
 }
 
 func (ic *ivContext) MergeLocations(key api.Key, dh api.DataProvider, merge api.MergeStrategy) dgo.Value {
+	if metrics.Enabled() {
+		defer metrics.ObserveLevel(dh.Hierarchy().Name(), time.Now())
+	}
 	return ic.WithDataProvider(dh, func() dgo.Value {
 		locations := dh.Hierarchy().Locations()
 		switch len(locations) {
@@ -229,17 +326,122 @@ func (ic *ivContext) MergeLocations(key api.Key, dh api.DataProvider, merge api.
 
 func (ic *ivContext) invokeWithLocation(dh api.DataProvider, location api.Location, key api.Key) dgo.Value {
 	if location == nil {
-		return dh.LookupKey(key, ic, nil)
+		return ic.recordSource(dh, nil, key, dh.LookupKey(key, ic, nil))
 	}
 	return ic.WithLocation(location, func() dgo.Value {
 		if location.Exists() {
-			return dh.LookupKey(key, ic, location)
+			return ic.recordSource(dh, location, key, dh.LookupKey(key, ic, location))
 		}
 		ic.ReportLocationNotFound()
 		return nil
 	})
 }
 
+func (ic *ivContext) RecordProvenance(key api.Key, producer dgo.Producer) (dgo.Value, *api.Provenance) {
+	saved := ic.sources
+	pr := &provenanceRecorder{root: key.Root()}
+	ic.sources = pr
+	defer func() {
+		ic.sources = saved
+	}()
+	v := producer()
+	if v == nil {
+		return nil, nil
+	}
+	return v, pr.found
+}
+
+func (ic *ivContext) TakeProvenance() *api.Provenance {
+	if ic.sources == nil {
+		return nil
+	}
+	p := ic.sources.found
+	ic.sources.found = nil
+	return p
+}
+
+func (ic *ivContext) SetProvenance(p *api.Provenance) {
+	if ic.sources != nil {
+		ic.sources.found = p
+	}
+}
+
+// positionOf returns the position of the root of the given key in the data found by the given provider at the
+// given location, or nil if that position is unknown.
+func positionOf(dh api.DataProvider, location api.Location, key api.Key) *api.Position {
+	if pp, ok := dh.(api.PositionProvider); ok {
+		return pp.Position(location, key.Root())
+	}
+	return nil
+}
+
+// recordSource records the given provider and location as the source of the given value when provenance is recorded
+// for the given key. They are also recorded as the supplier of the value when the found value is type checked.
+func (ic *ivContext) recordSource(dh api.DataProvider, location api.Location, key api.Key, v dgo.Value) dgo.Value {
+	if ic.sources != nil && ic.sources.root == key.Root() {
+		ic.sources.found = nil
+		if v != nil {
+			ic.sources.found = api.NewProvenance(&api.Source{Provider: dh, Location: location, Value: v})
+		}
+	}
+	if v != nil && ic.supplier != nil {
+		ic.supplier.record(dh, location, key)
+	}
+	return v
+}
+
+// record records the given provider and location as the supplier of a value for the given key
+func (sp *valueSupplier) record(dh api.DataProvider, location api.Location, key api.Key) {
+	sp.provider, sp.location, sp.key = dh, location, key
+	if sp.levels == nil {
+		sp.levels = map[string][]string{}
+	}
+	root, name := key.Root(), dh.Hierarchy().Name()
+	for _, n := range sp.levels[root] {
+		if n == name {
+			return
+		}
+	}
+	sp.levels[root] = append(sp.levels[root], name)
+}
+
+// assertValueType panics if the given value, which is the result of merging the values found in all hierarchy
+// levels, isn't an instance of the given type that was declared using the "type" entry of the lookup options. The
+// error names the hierarchy level and location that supplied the value when the first merge strategy is used, since
+// the value then stems from one level only. Otherwise it names all hierarchy levels that supplied a merged value.
+func (ic *ivContext) assertValueType(valueType dgo.Type, first bool, v dgo.Value) {
+	if valueType.Instance(v) {
+		return
+	}
+	sp := ic.supplier
+	var what string
+	switch {
+	case sp.provider == nil:
+		what = `found value`
+	case !first:
+		levels := sp.levels[sp.key.Root()]
+		if len(levels) == 1 {
+			what = fmt.Sprintf(`merged value of key '%s' found in hierarchy level '%s'`, sp.key.Source(), levels[0])
+		} else {
+			what = fmt.Sprintf(`merged value of key '%s' found in hierarchy levels '%s'`, sp.key.Source(),
+				strings.Join(levels, `', '`))
+		}
+	default:
+		dh, location := sp.provider, sp.location
+		var where string
+		if pos := positionOf(dh, location, sp.key); pos != nil {
+			where = fmt.Sprintf(`hierarchy level '%s' at %s`, dh.Hierarchy().Name(), pos)
+		} else if location == nil {
+			where = fmt.Sprintf(`hierarchy level '%s'`, dh.Hierarchy().Name())
+		} else {
+			where = fmt.Sprintf(`hierarchy level '%s', %s "%s"`, dh.Hierarchy().Name(), location.Kind(), location.Resolved())
+		}
+		what = fmt.Sprintf(`value of key '%s' found in %s`, sp.key.Source(), where)
+	}
+	panic(fmt.Errorf(`%s does not match the type declared in lookup_options: expected %s, got %s`,
+		what, valueType, typ.Generic(v.Type())))
+}
+
 func (ic *ivContext) Lookup(key api.Key, options dgo.Map) dgo.Value {
 	rootKey := key.Root()
 	if rootKey == `lookup_options` {
@@ -306,6 +508,15 @@ func (ic *ivContext) WithDataProvider(p api.DataProvider, producer dgo.Producer)
 	return producer()
 }
 
+func (ic *ivContext) WithDefaultHierarchy(producer dgo.Producer) dgo.Value {
+	if ic.explainer == nil {
+		return producer()
+	}
+	defer ic.explainer.Pop()
+	ic.explainer.PushDefaultHierarchy()
+	return producer()
+}
+
 func (ic *ivContext) WithInterpolation(expr string, producer dgo.Producer) dgo.Value {
 	if ic.explainer == nil {
 		return producer()
@@ -399,6 +610,15 @@ func (ic *ivContext) ForData() api.Invocation {
 	return &lic
 }
 
+func (ic *ivContext) ForDefaultHierarchy() api.Invocation {
+	lic := *ic
+	if !(lic.explainer == nil || !lic.explainer.OnlyOptions()) {
+		lic.explainer = nil
+	}
+	lic.mode = dataMode
+	return &lic
+}
+
 func (ic *ivContext) LookupOptions() dgo.Map {
 	return ic.luOpts
 }
@@ -431,12 +651,24 @@ func (ic *ivContext) ReportFound(key interface{}, value dgo.Value) {
 	}
 }
 
+func (ic *ivContext) ReportFoundAt(key interface{}, value dgo.Value, position string) {
+	if ic.explainer != nil {
+		ic.explainer.AcceptFoundAt(key, value, position)
+	}
+}
+
 func (ic *ivContext) ReportMergeResult(value dgo.Value) {
 	if ic.explainer != nil {
 		ic.explainer.AcceptMergeResult(value)
 	}
 }
 
+func (ic *ivContext) ReportLookupOptionsPattern(pattern string) {
+	if ic.explainer != nil {
+		ic.explainer.AcceptLookupOptionsPattern(pattern)
+	}
+}
+
 func (ic *ivContext) ReportMergeSource(source string) {
 	if ic.explainer != nil {
 		ic.explainer.AcceptMergeSource(source)
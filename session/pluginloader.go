@@ -21,6 +21,7 @@ import (
 	"github.com/lyraproj/dgo/loader"
 	"github.com/lyraproj/dgo/streamer"
 	"github.com/lyraproj/dgo/vf"
+	"github.com/lyraproj/hiera/metrics"
 	"github.com/lyraproj/hierasdk/hiera"
 	log "github.com/sirupsen/logrus"
 )
@@ -318,6 +319,8 @@ func makeOptions(pc hiera.ProviderContext) url.Values {
 }
 
 func (p *plugin) callPlugin(luType, name string, params url.Values) dgo.Value {
+	defer metrics.ObservePluginCall(name, time.Now())
+
 	var ad *url.URL
 	var err error
 
@@ -1,21 +1,38 @@
 package session
 
 import (
+	"fmt"
+	"regexp"
 	"strings"
 
 	"github.com/lyraproj/dgo/dgo"
+	"github.com/lyraproj/dgo/vf"
 	"github.com/lyraproj/hiera/api"
 	"github.com/lyraproj/hiera/internal"
 	"github.com/lyraproj/hiera/merge"
+	log "github.com/sirupsen/logrus"
 )
 
 type (
 	resolvedConfig struct {
-		cfg              api.Config
-		providers        []api.DataProvider
-		defaultProviders []api.DataProvider
-		lookupOptions    dgo.Map
-		moduleName       string
+		cfg                  api.Config
+		providers            []api.DataProvider
+		defaultProviders     []api.DataProvider
+		lookupOptions        *keyedOptions
+		defaultLookupOptions *keyedOptions
+		moduleName           string
+	}
+
+	// keyedOptions holds the lookup_options that are keyed by exact keys and the ones that are keyed by
+	// regular expressions.
+	keyedOptions struct {
+		exact    dgo.Map
+		patterns []*patternOptions
+	}
+
+	patternOptions struct {
+		pattern *regexp.Regexp
+		options dgo.Map
 	}
 )
 
@@ -52,13 +69,67 @@ func (r *resolvedConfig) DefaultHierarchy() []api.DataProvider {
 }
 
 func (r *resolvedConfig) LookupOptions(key api.Key) dgo.Map {
+	m, _ := r.MatchLookupOptions(key)
+	return m
+}
+
+func (r *resolvedConfig) DefaultLookupOptions(key api.Key) dgo.Map {
+	m, _ := r.MatchDefaultLookupOptions(key)
+	return m
+}
+
+func (r *resolvedConfig) MatchLookupOptions(key api.Key) (dgo.Map, string) {
+	return r.optionsFor(r.lookupOptions, key)
+}
+
+func (r *resolvedConfig) MatchDefaultLookupOptions(key api.Key) (dgo.Map, string) {
+	return r.optionsFor(r.defaultLookupOptions, key)
+}
+
+func (r *resolvedConfig) optionsFor(lookupOptions *keyedOptions, key api.Key) (dgo.Map, string) {
 	root := key.Root()
-	if r.lookupOptions != nil && (r.moduleName == `` || strings.HasPrefix(root, r.moduleName+`::`)) {
-		if m, ok := r.lookupOptions.Get(root).(dgo.Map); ok {
-			return m
+	if lookupOptions != nil && (r.moduleName == `` || strings.HasPrefix(root, r.moduleName+`::`)) {
+		if m, ok := lookupOptions.exact.Get(root).(dgo.Map); ok {
+			return m, ``
+		}
+		for _, po := range lookupOptions.patterns {
+			if po.pattern.MatchString(root) {
+				return po.options, po.pattern.String()
+			}
 		}
 	}
-	return nil
+	return nil, ``
+}
+
+// newKeyedOptions separates the keys of the given lookup_options hash into exact keys and regular expression
+// keys. A key is a regular expression when its options contains the entry "match: regex". The regular
+// expressions are matched in the order they appear in the hash. In a module, a regular expression is only
+// used when it starts with "^<module name>::". A warning is logged for other regular expressions.
+func newKeyedOptions(lookupOptions dgo.Map, moduleName string) *keyedOptions {
+	ko := &keyedOptions{exact: vf.MapWithCapacity(lookupOptions.Len())}
+	lookupOptions.EachEntry(func(e dgo.MapEntry) {
+		k := e.Key().String()
+		m, ok := e.Value().(dgo.Map)
+		if !ok {
+			return
+		}
+		match := m.Get(`match`)
+		if match == nil || match.String() != `regex` {
+			ko.exact.Put(k, m)
+			return
+		}
+		if moduleName != `` && !strings.HasPrefix(k, `^`+moduleName+`::`) {
+			log.Warnf(`lookup_options key '%s' of module '%s' is ignored since it doesn't start with '^%s::'`,
+				k, moduleName, moduleName)
+			return
+		}
+		rx, err := regexp.Compile(k)
+		if err != nil {
+			panic(fmt.Errorf(`lookup_options key '%s' is not a valid regular expression: %s`, k, err.Error()))
+		}
+		ko.patterns = append(ko.patterns, &patternOptions{pattern: rx, options: m.Without(`match`)})
+	})
+	return ko
 }
 
 func (r *resolvedConfig) Resolve(ic api.Invocation) {
@@ -66,19 +137,31 @@ func (r *resolvedConfig) Resolve(ic api.Invocation) {
 	r.providers = r.CreateProviders(icc, r.cfg.Hierarchy())
 	r.defaultProviders = r.CreateProviders(icc, r.cfg.DefaultHierarchy())
 
+	lic := ic.ForLookupOptions()
+	r.lookupOptions = r.resolveLookupOptions(lic, r.providers)
+	if len(r.defaultProviders) > 0 {
+		// The default_hierarchy has its own lookup_options that are only used when the lookup falls through
+		// to that hierarchy.
+		lic.WithDefaultHierarchy(func() dgo.Value {
+			r.defaultLookupOptions = r.resolveLookupOptions(lic, r.defaultProviders)
+			return nil
+		})
+	}
+}
+
+func (r *resolvedConfig) resolveLookupOptions(lic api.Invocation, providers []api.DataProvider) *keyedOptions {
 	ms := merge.GetStrategy(`deep`, nil)
 	k := api.NewKey(`lookup_options`)
-	lic := ic.ForLookupOptions()
 	v := lic.WithLookup(k, func() dgo.Value {
-		return ms.MergeLookup(r.Hierarchy(), lic, func(prv interface{}) dgo.Value {
+		return ms.MergeLookup(providers, lic, func(prv interface{}) dgo.Value {
 			pr := prv.(api.DataProvider)
 			return lic.MergeLocations(k, pr, ms)
 		})
 	})
-
 	if lm, ok := v.(dgo.Map); ok {
-		r.lookupOptions = lm
+		return newKeyedOptions(lm, r.moduleName)
 	}
+	return nil
 }
 
 func (r *resolvedConfig) CreateProviders(ic api.Invocation, hierarchy []api.Entry) []api.DataProvider {
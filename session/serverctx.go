@@ -3,6 +3,7 @@ package session
 import (
 	"github.com/lyraproj/dgo/dgo"
 	"github.com/lyraproj/hiera/api"
+	"github.com/lyraproj/hiera/metrics"
 	"github.com/lyraproj/hierasdk/hiera"
 )
 
@@ -37,7 +38,9 @@ func (c *serverCtx) CacheAll(hash dgo.Map) {
 }
 
 func (c *serverCtx) CachedValue(key string) (dgo.Value, bool) {
-	if v, ok := c.invocation.TopProviderCache().Load(key); ok {
+	v, ok := c.invocation.TopProviderCache().Load(key)
+	metrics.ObserveCache(metrics.ProviderCache, ok)
+	if ok {
 		return v.(dgo.Value), true
 	}
 	return nil, false
@@ -9,6 +9,7 @@ import (
 	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"unicode"
 
 	"github.com/lyraproj/dgo/streamer/pcore"
@@ -32,12 +33,17 @@ type session struct {
 	vars     map[string]interface{}
 	scope    dgo.Keyed
 	loader   dgo.Loader
+	caches   atomic.Value
+}
+
+// caches are the caches that are replaced when a session is reloaded
+type caches struct {
+	shared      *sync.Map
+	topProvider *sync.Map
 }
 
-const hieraCacheKey = `Hiera::Cache`
 const hieraTopProviderKey = `Hiera::TopProvider`
 const hieraSessionOptionsKey = `Hiera::SessionOptions`
-const hieraTopProviderCacheKey = `Hiera::TopProvider::Cache`
 const hieraPluginRegistry = `Hiera::Plugins`
 
 // New creates a new Hiera Session which, among other things, holds on to a synchronized
@@ -90,13 +96,12 @@ func New(parent context.Context, topProvider hiera.LookupKey, oif interface{}, l
 	options.Freeze()
 
 	vars := map[string]interface{}{
-		hieraCacheKey:            &sync.Map{},
-		hieraTopProviderKey:      topProvider,
-		hieraTopProviderCacheKey: &sync.Map{},
-		hieraSessionOptionsKey:   options,
-		hieraPluginRegistry:      &pluginRegistry{}}
+		hieraTopProviderKey:    topProvider,
+		hieraSessionOptionsKey: options,
+		hieraPluginRegistry:    &pluginRegistry{}}
 
 	s := &session{Context: parent, aliasMap: tf.DefaultAliases(), vars: vars, dialect: dialect, scope: scope}
+	s.Reload()
 	s.loader = s.newHieraLoader(ldr)
 	return s
 }
@@ -138,7 +143,7 @@ func (s *session) Invocation(si interface{}, explainer api.Explainer) api.Invoca
 	} else {
 		scope = &nestedScope{s.Scope(), api.ToMap(`invocation scope`, si)}
 	}
-	return newInvocation(s, scope, explainer)
+	return newInvocation(s, s.currentCaches(), scope, explainer)
 }
 
 // KillPlugins will ensure that all plugins started by this executable are gracefully terminated if possible or
@@ -209,8 +214,16 @@ func (s *session) TopProvider() hiera.LookupKey {
 }
 
 func (s *session) TopProviderCache() *sync.Map {
-	if v, ok := s.Get(hieraTopProviderCacheKey).(*sync.Map); ok {
-		return v
+	return s.currentCaches().topProvider
+}
+
+func (s *session) Reload() {
+	s.caches.Store(&caches{shared: &sync.Map{}, topProvider: &sync.Map{}})
+}
+
+func (s *session) currentCaches() *caches {
+	if c, ok := s.caches.Load().(*caches); ok {
+		return c
 	}
 	panic(notInitialized())
 }
@@ -229,10 +242,7 @@ func notInitialized() error {
 }
 
 func (s *session) SharedCache() *sync.Map {
-	if v, ok := s.Get(hieraCacheKey).(*sync.Map); ok {
-		return v
-	}
-	panic(notInitialized())
+	return s.currentCaches().shared
 }
 
 func (s *session) newHieraLoader(p dgo.Loader) dgo.Loader {